@@ -17,12 +17,11 @@ limitations under the License.
 package v1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 
 	"github.com/kube-all/go-argocd/models"
-	"github.com/parnurzeal/gorequest"
+	"net/http"
 )
 
 type AccountsService struct {
@@ -30,106 +29,95 @@ type AccountsService struct {
 }
 
 //ListAccounts returns the list of accounts
-func (s *AccountsService) ListAccounts() (accountList models.AccountsList, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"account").
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &accountList)
-	} else {
-		_ = json.Unmarshal([]byte(data), &err)
-	}
-	err = s.client.ErrsWrapper(errs)
+func (s *AccountsService) ListAccounts() (accountList models.AccountsList, resp *http.Response, err error) {
+	return s.ListAccountsWithContext(context.Background())
+}
+
+//ListAccountsWithContext is ListAccounts with a context controlling cancellation and deadlines
+func (s *AccountsService) ListAccountsWithContext(ctx context.Context) (accountList models.AccountsList, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "AccountsService.ListAccounts", http.MethodGet, apiV1Prefix+"account").
+		Do(&accountList)
 	return
 }
 
 //CanI checks if the current account has permission to perform an action
-func (s *AccountsService) CanI(request models.CanIRequest) (response models.CanIResponse, resp gorequest.Response, err error) {
+func (s *AccountsService) CanI(request models.CanIRequest) (response models.CanIResponse, resp *http.Response, err error) {
+	return s.CanIWithContext(context.Background(), request)
+}
+
+//CanIWithContext is CanI with a context controlling cancellation and deadlines
+func (s *AccountsService) CanIWithContext(ctx context.Context, request models.CanIRequest) (response models.CanIResponse, resp *http.Response, err error) {
 	var p string
 	if len(request.Subresource) > 0 {
 		p = apiV1Prefix + fmt.Sprintf("account/can-i/%s/%s/%s", request.Resource, request.Action, request.Subresource)
 	} else {
 		p = apiV1Prefix + fmt.Sprintf("account/can-i/%s/%s", request.Resource, request.Action)
 	}
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, p).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &response)
-	}
-	err = s.client.ErrsWrapper(errs)
+	resp, err = s.client.
+		newRequest(ctx, "AccountsService.CanI", http.MethodGet, p).
+		Do(&response)
 
 	return
 }
 
 //UpdatePassword updates an account's password to a new value
-func (s *AccountsService) UpdatePassword(request models.UpdatePasswordRequest) (success bool, resp gorequest.Response, err error) {
-	var (
-		errs []error
-	)
-	resp, _, errs = s.client.
-		newRequest(gorequest.PUT, apiV1Prefix+"account/password").
+func (s *AccountsService) UpdatePassword(request models.UpdatePasswordRequest) (success bool, resp *http.Response, err error) {
+	return s.UpdatePasswordWithContext(context.Background(), request)
+}
+
+//UpdatePasswordWithContext is UpdatePassword with a context controlling cancellation and deadlines
+func (s *AccountsService) UpdatePasswordWithContext(ctx context.Context, request models.UpdatePasswordRequest) (success bool, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "AccountsService.UpdatePassword", http.MethodPut, apiV1Prefix+"account/password").
 		SendMap(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		success = true
-	}
-	err = s.client.ErrsWrapper(errs)
+		Sensitive().
+		Do(nil)
+	success = err == nil
 	return
 }
 
 //GetAccount returns an account
-func (s *AccountsService) GetAccount(name string) (response models.Account, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"account/"+name).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &response)
-	}
-	err = s.client.ErrsWrapper(errs)
+func (s *AccountsService) GetAccount(name string) (response models.Account, resp *http.Response, err error) {
+	return s.GetAccountWithContext(context.Background(), name)
+}
+
+//GetAccountWithContext is GetAccount with a context controlling cancellation and deadlines
+func (s *AccountsService) GetAccountWithContext(ctx context.Context, name string) (response models.Account, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "AccountsService.GetAccount", http.MethodGet, apiV1Prefix+"account/"+name).
+		Do(&response)
 	return
 }
 
 //CreateToken creates a token
-func (s *AccountsService) CreateToken(name, id string, expiresIn int64) (token models.CreateTokenResponse, resp gorequest.Response, err error) {
-	var (
-		errs []error
-	)
+func (s *AccountsService) CreateToken(name, id string, expiresIn int64) (token models.CreateTokenResponse, resp *http.Response, err error) {
+	return s.CreateTokenWithContext(context.Background(), name, id, expiresIn)
+}
+
+//CreateTokenWithContext is CreateToken with a context controlling cancellation and deadlines
+func (s *AccountsService) CreateTokenWithContext(ctx context.Context, name, id string, expiresIn int64) (token models.CreateTokenResponse, resp *http.Response, err error) {
 	sendMap := make(map[string]interface{})
 	sendMap["name"] = name
 	sendMap["id"] = id
 	sendMap["expiresIn"] = expiresIn
-	resp, _, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+fmt.Sprintf("account/%s/token", name)).
+	resp, err = s.client.
+		newRequest(ctx, "AccountsService.CreateToken", http.MethodPost, apiV1Prefix+fmt.Sprintf("account/%s/token", name)).
 		SendMap(sendMap).
-		EndStruct(&token)
-	err = s.client.ErrsWrapper(errs)
+		Do(&token)
 	return
 }
 
 //DeleteToken deletes a token
-func (s *AccountsService) DeleteToken(name, id string) (success bool, resp gorequest.Response, err error) {
-	var (
-		errs []error
-	)
-	resp, _, errs = s.client.
-		newRequest(gorequest.DELETE, apiV1Prefix+fmt.Sprintf("account/%s/token/%s", name, id)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		success = true
-	}
-	err = s.client.ErrsWrapper(errs)
+func (s *AccountsService) DeleteToken(name, id string) (success bool, resp *http.Response, err error) {
+	return s.DeleteTokenWithContext(context.Background(), name, id)
+}
+
+//DeleteTokenWithContext is DeleteToken with a context controlling cancellation and deadlines
+func (s *AccountsService) DeleteTokenWithContext(ctx context.Context, name, id string) (success bool, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "AccountsService.DeleteToken", http.MethodDelete, apiV1Prefix+fmt.Sprintf("account/%s/token/%s", name, id)).
+		Do(nil)
+	success = err == nil
 	return
 }
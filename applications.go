@@ -17,17 +17,35 @@ limitations under the License.
 package v1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/argoproj/argo-cd/v2/reposerver/apiclient"
-	"github.com/parnurzeal/gorequest"
 	v1 "k8s.io/api/core/v1"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
+// Sync options understood by Argo CD, for ApplicationSyncRequest.SyncOptions.
+const (
+	SyncOptionCreateNamespace             = "CreateNamespace=true"
+	SyncOptionServerSideApply             = "ServerSideApply=true"
+	SyncOptionReplace                     = "Replace=true"
+	SyncOptionPruneLast                   = "PruneLast=true"
+	SyncOptionApplyOutOfSyncOnly          = "ApplyOutOfSyncOnly=true"
+	SyncOptionRespectIgnoreDifferences    = "RespectIgnoreDifferences=true"
+	SyncOptionSkipDryRunOnMissingResource = "SkipDryRunOnMissingResource=true"
+	SyncOptionDisableValidation           = "Validate=false"
+)
+
+//SyncOptions returns the sync options of an ApplicationSyncRequest, such as
+//SyncOptionCreateNamespace
+func SyncOptions(options ...string) *application.SyncOptions {
+	return &application.SyncOptions{Items: options}
+}
+
 type ApplicationResourceRequest struct {
 	Name         string `json:"name"`
 	Namespace    string `json:"namespace"`
@@ -42,29 +60,27 @@ type ApplicationService struct {
 }
 
 //List returns list of applications
-func (s *ApplicationService) List(request application.ApplicationQuery) (result v1alpha1.ApplicationList, resp gorequest.Response, err error) {
+func (s *ApplicationService) List(request application.ApplicationQuery) (result v1alpha1.ApplicationList, resp *http.Response, err error) {
+	return s.ListWithContext(context.Background(), request)
+}
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications").
+//ListWithContext is List with a context controlling cancellation and deadlines
+func (s *ApplicationService) ListWithContext(ctx context.Context, request application.ApplicationQuery) (result v1alpha1.ApplicationList, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.List", http.MethodGet, apiV1Prefix+"applications").
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //Create creates an application
-func (s *ApplicationService) Create(request application.ApplicationCreateRequest) (result v1alpha1.Application, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
+func (s *ApplicationService) Create(request application.ApplicationCreateRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	return s.CreateWithContext(context.Background(), request)
+}
+
+//CreateWithContext is Create with a context controlling cancellation and deadlines
+func (s *ApplicationService) CreateWithContext(ctx context.Context, request application.ApplicationCreateRequest) (result v1alpha1.Application, resp *http.Response, err error) {
 	queryMap := make(map[string]bool)
 	if request.Upsert != nil {
 		queryMap["upsert"] = *request.Upsert
@@ -72,220 +88,272 @@ func (s *ApplicationService) Create(request application.ApplicationCreateRequest
 	if request.Validate != nil {
 		queryMap["validate"] = *request.Validate
 	}
-	resp, data, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+"applications").
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.Create", http.MethodPost, apiV1Prefix+"applications").
+		Attr(AttributeApplication, request.Application.Name).
+		Attr(AttributeProject, request.Application.Spec.Project).
+		Attr(AttributeCluster, request.Application.Spec.Destination.Server).
 		SendStruct(request.Application).
 		Query(&queryMap).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Idempotent(queryMap["upsert"]).
+		Do(&result)
 	return
 }
 
 //ManagedResources returns list of managed resources
-func (s *ApplicationService) ManagedResources(request application.ResourcesQuery) (results []*v1alpha1.ResourceDiff, resp gorequest.Response, err error) {
+func (s *ApplicationService) ManagedResources(request application.ResourcesQuery) (results []*v1alpha1.ResourceDiff, resp *http.Response, err error) {
+	return s.ManagedResourcesWithContext(context.Background(), request)
+}
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+*request.ApplicationName+"/managed-resources").
+//ManagedResourcesWithContext is ManagedResources with a context controlling cancellation and deadlines
+func (s *ApplicationService) ManagedResourcesWithContext(ctx context.Context, request application.ResourcesQuery) (results []*v1alpha1.ResourceDiff, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.ApplicationName)
+	request.ApplicationName = &name
+	var response application.ManagedResourcesResponse
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.ManagedResources", http.MethodGet, apiV1Prefix+"applications/"+name+"/managed-resources").
+		Attr(AttributeApplication, name).
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &results)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&response)
+	results = response.Items
 	return
 }
 
 //ResourceTree returns resource tree
-func (s *ApplicationService) ResourceTree(request application.ResourcesQuery) (result v1alpha1.ApplicationTree, resp gorequest.Response, err error) {
+func (s *ApplicationService) ResourceTree(request application.ResourcesQuery) (result v1alpha1.ApplicationTree, resp *http.Response, err error) {
+	return s.ResourceTreeWithContext(context.Background(), request)
+}
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+*request.ApplicationName+"/resource-tree").
+//ResourceTreeWithContext is ResourceTree with a context controlling cancellation and deadlines
+func (s *ApplicationService) ResourceTreeWithContext(ctx context.Context, request application.ResourcesQuery) (result v1alpha1.ApplicationTree, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.ApplicationName)
+	request.ApplicationName = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.ResourceTree", http.MethodGet, apiV1Prefix+"applications/"+name+"/resource-tree").
+		Attr(AttributeApplication, name).
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
-//Get returns an application by name
-func (s *ApplicationService) Get(request application.ApplicationQuery) (result v1alpha1.Application, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+*request.Name+"/resource-tree").
+//Get returns an application by name. A refresh of the query
+//(normal or hard) has the application reconciled before it is returned, and
+//its projects restrict the application returned to them.
+func (s *ApplicationService) Get(request application.ApplicationQuery) (result v1alpha1.Application, resp *http.Response, err error) {
+	return s.GetWithContext(context.Background(), request)
+}
+
+//GetWithContext is Get with a context controlling cancellation and deadlines
+func (s *ApplicationService) GetWithContext(ctx context.Context, request application.ApplicationQuery) (result v1alpha1.Application, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.Name)
+	request.Name = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.Get", http.MethodGet, apiV1Prefix+"applications/"+name).
+		Attr(AttributeApplication, name).
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
+//splitApplicationName splits a name qualified as namespace/name. Every
+//method taking an application name accepts this form, the one of the argocd
+//CLI, for applications outside the Argo CD namespace.
+func splitApplicationName(qualified string) (namespace, name string) {
+	if i := strings.Index(qualified, "/"); i >= 0 {
+		return qualified[:i], qualified[i+1:]
+	}
+	return "", qualified
+}
+
+//appNamespaceQuery returns the query selecting the namespace of an
+//application, empty for the Argo CD namespace
+func appNamespaceQuery(namespace string) string {
+	if len(namespace) == 0 {
+		return ""
+	}
+	return "appNamespace=" + url.QueryEscape(namespace)
+}
+
 //Update updates an application
-func (s *ApplicationService) Update(request application.ApplicationUpdateRequest) (result v1alpha1.Application, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.PUT, apiV1Prefix+"applications/"+request.Application.Name).
+func (s *ApplicationService) Update(request application.ApplicationUpdateRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	return s.UpdateWithContext(context.Background(), request)
+}
+
+//UpdateWithContext is Update with a context controlling cancellation and deadlines
+func (s *ApplicationService) UpdateWithContext(ctx context.Context, request application.ApplicationUpdateRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.Update", http.MethodPut, apiV1Prefix+"applications/"+request.Application.Name).
+		Attr(AttributeApplication, request.Application.Name).
+		Attr(AttributeProject, request.Application.Spec.Project).
+		Attr(AttributeCluster, request.Application.Spec.Destination.Server).
 		SendStruct(request.Application).
 		Query(fmt.Sprintf("validate=%t", *request.Validate)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
-//Patch patch an application
-func (s *ApplicationService) Patch(request application.ApplicationPatchRequest) (results []*v1alpha1.ResourceDiff, resp gorequest.Response, err error) {
+//Patch patches an application and returns the patched application
+func (s *ApplicationService) Patch(request application.ApplicationPatchRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	return s.PatchWithContext(context.Background(), request)
+}
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.PATCH, apiV1Prefix+"applications/"+*request.Name).
+//PatchWithContext is Patch with a context controlling cancellation and deadlines
+func (s *ApplicationService) PatchWithContext(ctx context.Context, request application.ApplicationPatchRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.Name)
+	request.Name = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.Patch", http.MethodPatch, apiV1Prefix+"applications/"+name).
+		Attr(AttributeApplication, name).
 		SendStruct(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &results)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
 //ListResourceEvents returns a list of event resources
-func (s *ApplicationService) ListResourceEvents(request application.ApplicationResourceEventsQuery) (result v1.EventList, resp gorequest.Response, err error) {
+func (s *ApplicationService) ListResourceEvents(request application.ApplicationResourceEventsQuery) (result v1.EventList, resp *http.Response, err error) {
+	return s.ListResourceEventsWithContext(context.Background(), request)
+}
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+*request.Name+"/events").
+//ListResourceEventsWithContext is ListResourceEvents with a context controlling cancellation and deadlines
+func (s *ApplicationService) ListResourceEventsWithContext(ctx context.Context, request application.ApplicationResourceEventsQuery) (result v1.EventList, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.Name)
+	request.Name = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.ListResourceEvents", http.MethodGet, apiV1Prefix+"applications/"+name+"/events").
+		Attr(AttributeApplication, name).
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
 //ApplicationPodLogs returns stream of log entries for the specified pod. Pod
-func (s *ApplicationService) ApplicationPodLogs(request application.ApplicationPodLogsQuery) (result application.LogEntry, resp gorequest.Response, err error) {
+//
+//Deprecated: the server streams entries, which this decodes as a single one.
+//Use StreamPodLogs or PodLogsReader.
+func (s *ApplicationService) ApplicationPodLogs(request application.ApplicationPodLogsQuery) (result application.LogEntry, resp *http.Response, err error) {
+	return s.ApplicationPodLogsWithContext(context.Background(), request)
+}
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+*request.Name+"/logs").
+//ApplicationPodLogsWithContext is ApplicationPodLogs with a context controlling cancellation and deadlines
+func (s *ApplicationService) ApplicationPodLogsWithContext(ctx context.Context, request application.ApplicationPodLogsQuery) (result application.LogEntry, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.Name)
+	request.Name = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.ApplicationPodLogs", http.MethodGet, apiV1Prefix+"applications/"+name+"/logs").
+		Attr(AttributeApplication, name).
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
 //GetManifests returns application manifests
-func (s *ApplicationService) GetManifests(name, revision string) (result apiclient.ManifestResponse, resp gorequest.Response, err error) {
+func (s *ApplicationService) GetManifests(name, revision string) (result apiclient.ManifestResponse, resp *http.Response, err error) {
+	return s.GetManifestsWithContext(context.Background(), name, revision)
+}
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+name+"/manifests").
+//GetManifestsWithContext is GetManifests with a context controlling cancellation and deadlines
+func (s *ApplicationService) GetManifestsWithContext(ctx context.Context, name, revision string) (result apiclient.ManifestResponse, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(name)
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.GetManifests", http.MethodGet, apiV1Prefix+"applications/"+name+"/manifests").
+		Attr(AttributeApplication, name).
 		Query(fmt.Sprintf("revision=%s", revision)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
 //TerminateOperation terminates the currently running operation
-func (s *ApplicationService) TerminateOperation(name string) (success bool, resp gorequest.Response, err error) {
+func (s *ApplicationService) TerminateOperation(name string) (success bool, resp *http.Response, err error) {
+	return s.TerminateOperationWithContext(context.Background(), name)
+}
 
-	var (
-		errs []error
-	)
-	resp, _, errs = s.client.
-		newRequest(gorequest.DELETE, apiV1Prefix+"applications/"+name+"/operation").
-		End()
-	if resp.StatusCode == http.StatusOK {
-		success = true
-	}
-	err = s.client.ErrsWrapper(errs)
+//TerminateOperationWithContext is TerminateOperation with a context controlling cancellation and deadlines
+func (s *ApplicationService) TerminateOperationWithContext(ctx context.Context, name string) (success bool, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(name)
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.TerminateOperation", http.MethodDelete, apiV1Prefix+"applications/"+name+"/operation").
+		Attr(AttributeApplication, name).
+		Query(appNamespaceQuery(namespace)).
+		Do(nil)
+	success = err == nil
+	return
+}
+
+//Sync starts a sync of the application named in request, returning the
+//application with the operation started. Its strategy, resources, sync
+//options, retry strategy and infos apply to this sync only.
+func (s *ApplicationService) Sync(request application.ApplicationSyncRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	return s.SyncWithContext(context.Background(), request)
+}
+
+//SyncWithContext is Sync with a context controlling cancellation and deadlines
+func (s *ApplicationService) SyncWithContext(ctx context.Context, request application.ApplicationSyncRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.Name)
+	request.Name = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.Sync", http.MethodPost, apiV1Prefix+"applications/"+name+"/sync").
+		Attr(AttributeApplication, name).
+		SendStruct(&request).
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
 //PodLogs returns stream of log entries for the specified pod. Pod
-func (s *ApplicationService) PodLogs(request application.ApplicationPodLogsQuery) (result application.LogEntry, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+*request.Name+"/pods/"+*request.PodName+"/logs").
+//
+//Deprecated: the server streams entries, which this decodes as a single one.
+//Use StreamPodLogs or PodLogsReader.
+func (s *ApplicationService) PodLogs(request application.ApplicationPodLogsQuery) (result application.LogEntry, resp *http.Response, err error) {
+	return s.PodLogsWithContext(context.Background(), request)
+}
+
+//PodLogsWithContext is PodLogs with a context controlling cancellation and deadlines
+func (s *ApplicationService) PodLogsWithContext(ctx context.Context, request application.ApplicationPodLogsQuery) (result application.LogEntry, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.Name)
+	request.Name = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.PodLogs", http.MethodGet, apiV1Prefix+"applications/"+name+"/pods/"+*request.PodName+"/logs").
+		Attr(AttributeApplication, name).
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
 //GetResource returns single application resource
-func (s *ApplicationService) GetResource(request ApplicationResourceRequest) (result application.ApplicationResourceResponse, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+request.Name+"/resource").
+func (s *ApplicationService) GetResource(request ApplicationResourceRequest) (result application.ApplicationResourceResponse, resp *http.Response, err error) {
+	return s.GetResourceWithContext(context.Background(), request)
+}
+
+//GetResourceWithContext is GetResource with a context controlling cancellation and deadlines
+func (s *ApplicationService) GetResourceWithContext(ctx context.Context, request ApplicationResourceRequest) (result application.ApplicationResourceResponse, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(request.Name)
+	request.Name = name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.GetResource", http.MethodGet, apiV1Prefix+"applications/"+name+"/resource").
+		Attr(AttributeApplication, name).
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
 
 //
 ////PatchResource patch single application resource
-//func (s *ApplicationService) PatchResource(request application.ApplicationResourcePatchRequest) (result application.ApplicationResourceResponse, resp gorequest.Response, err error) {
+//func (s *ApplicationService) PatchResource(request application.ApplicationResourcePatchRequest) (result application.ApplicationResourceResponse, resp *http.Response, err error) {
 //	var (
 //		data string
 //		errs []error
 //	)
 //	resp, data, errs = s.client.
-//		newRequest(gorequest.POST, apiV1Prefix+"applications/"+*request.Name+"/resource").
+//		newRequest(http.MethodPost, apiV1Prefix+"applications/"+*request.Name+"/resource").
 //		SendString(request.String()).
 //		Query(&request).
 //		End()
@@ -302,12 +370,17 @@ func (s *ApplicationService) GetResource(request ApplicationResourceRequest) (re
 //}
 
 //ListResourceActions returns list of resource actions
-func (s *ApplicationService) ListResourceActions(request ApplicationResourceRequest) (result application.ResourceActionsListResponse, resp gorequest.Response, err error) {
+func (s *ApplicationService) ListResourceActions(request ApplicationResourceRequest) (result application.ResourceActionsListResponse, resp *http.Response, err error) {
+	return s.ListResourceActionsWithContext(context.Background(), request)
+}
+
+//ListResourceActionsWithContext is ListResourceActions with a context controlling cancellation and deadlines
+func (s *ApplicationService) ListResourceActionsWithContext(ctx context.Context, request ApplicationResourceRequest) (result application.ResourceActionsListResponse, resp *http.Response, err error) {
 	var (
-		data    string
-		errs    []error
 		queries []string
 	)
+	namespace, name := splitApplicationName(request.Name)
+	request.Name = name
 	queryMap := make(map[string]string)
 	queryMap["name"] = request.Name
 	queryMap["namespace"] = request.Namespace
@@ -318,13 +391,11 @@ func (s *ApplicationService) ListResourceActions(request ApplicationResourceRequ
 	for k, v := range queryMap {
 		queries = append(queries, fmt.Sprintf("%s=%s", k, v))
 	}
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"applications/"+request.Name+"/resource/actions").
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.ListResourceActions", http.MethodGet, apiV1Prefix+"applications/"+name+"/resource/actions").
+		Attr(AttributeApplication, name).
 		Query(strings.Join(queries, "&")).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
 	return
 }
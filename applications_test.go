@@ -17,9 +17,13 @@ limitations under the License.
 package v1
 
 import (
+	"encoding/json"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/ghodss/yaml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
@@ -135,3 +139,123 @@ func TestListResourceActions(t *testing.T) {
 	}
 	t.Log(app)
 }
+
+func TestApplicationSync(t *testing.T) {
+	name, revision, prune := "guestbook", "main", true
+	factor := int64(2)
+	request := application.ApplicationSyncRequest{
+		Name:     &name,
+		Revision: &revision,
+		Prune:    &prune,
+		Strategy: &v1alpha1.SyncStrategy{Apply: &v1alpha1.SyncStrategyApply{Force: true}},
+		Resources: []*v1alpha1.SyncOperationResource{
+			{Group: "apps", Kind: "Deployment", Name: "guestbook-ui", Namespace: "default"},
+		},
+		Infos: []*v1alpha1.Info{{Name: "reason", Value: "release"}},
+		RetryStrategy: &v1alpha1.RetryStrategy{
+			Limit:   3,
+			Backoff: &v1alpha1.Backoff{Duration: "5s", Factor: &factor, MaxDuration: "1m"},
+		},
+		SyncOptions: SyncOptions(SyncOptionCreateNamespace, SyncOptionServerSideApply),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var got application.ApplicationSyncRequest
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil || r.Method != http.MethodPost ||
+			r.URL.Path != "/api/v1/applications/guestbook/sync" {
+			t.Errorf("unexpected request %s %s: %v", r.Method, r.URL, err)
+		}
+		if !reflect.DeepEqual(got, request) {
+			t.Errorf("unexpected sync request %+v", got)
+		}
+		_, _ = w.Write([]byte(`{"metadata":{"name":"guestbook"},"operation":{"sync":{"revision":"main","prune":true,` +
+			`"syncOptions":["CreateNamespace=true","ServerSideApply=true"]}},"status":{"operationState":{"phase":"Running"}}}`))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, _, err := client.Applications.Sync(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Operation == nil || app.Operation.Sync.Revision != "main" || app.Status.OperationState.Phase != "Running" {
+		t.Fatalf("unexpected application %+v", app)
+	}
+}
+
+func TestApplicationGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v1/applications/guestbook" || q.Get("name") != "guestbook" || q.Get("refresh") != "hard" ||
+			q.Get("resourceVersion") != "5" || q.Get("projects") != "default" || q.Get("appNamespace") != "team" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		_, _ = w.Write([]byte(`{"metadata":{"name":"guestbook","namespace":"team"},"spec":{"project":"default"}}`))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, refresh, resourceVersion := "team/guestbook", "hard", "5"
+	app, _, err := client.Applications.Get(application.ApplicationQuery{
+		Name: &name, Refresh: &refresh, ResourceVersion: &resourceVersion, Projects: []string{"default"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Name != "guestbook" || app.Spec.Project != "default" {
+		t.Fatalf("unexpected application %+v", app)
+	}
+}
+
+func TestApplicationManagedResourcesItems(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/applications/guestbook/managed-resources" || r.URL.Query().Get("appNamespace") != "team" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		_, _ = w.Write([]byte(`{"items":[{"kind":"Deployment","name":"guestbook-ui","namespace":"default","modified":true},{"kind":"Service","name":"guestbook-ui","namespace":"default"}]}`))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "team/guestbook"
+	resources, _, err := client.Applications.ManagedResources(application.ResourcesQuery{ApplicationName: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resources) != 2 || resources[0].Kind != "Deployment" || !resources[0].Modified || resources[1].Kind != "Service" {
+		t.Fatalf("unexpected resources %+v", resources)
+	}
+}
+
+func TestApplicationPatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request application.ApplicationPatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		if r.Method != http.MethodPatch || r.URL.Path != "/api/v1/applications/guestbook" || request.GetPatchType() != "merge" {
+			t.Errorf("unexpected request %s %s %+v", r.Method, r.URL, request)
+		}
+		_, _ = w.Write([]byte(`{"metadata":{"name":"guestbook"},"spec":{"project":"default","source":{"targetRevision":"v2"}}}`))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, patch, patchType := "guestbook", `{"spec":{"source":{"targetRevision":"v2"}}}`, "merge"
+	app, _, err := client.Applications.Patch(application.ApplicationPatchRequest{
+		Name: &name, Patch: &patch, PatchType: &patchType,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Name != "guestbook" || app.Spec.Source.TargetRevision != "v2" {
+		t.Fatalf("unexpected application %+v", app)
+	}
+}
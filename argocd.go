@@ -17,13 +17,16 @@ limitations under the License.
 package v1
 
 import (
-	"crypto/tls"
+	"context"
 	"errors"
-	"fmt"
-	"github.com/parnurzeal/gorequest"
-	"k8s.io/klog/v2"
+	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -32,14 +35,39 @@ const (
 )
 
 type ClientOptionFunc func(*Client) error
+
+// Client is safe for concurrent use by multiple goroutines: every call builds
+// its own *http.Request and the only mutable state, the token, is guarded.
 type Client struct {
-	client     *gorequest.SuperAgent
+	httpClient *http.Client
+	transport  http.RoundTripper
 	baseURL    *url.URL
 	apiVersion string
 	UserAgent  string
+	headers    http.Header
+	mu         sync.RWMutex
 	token      string
-	username   string
-	password   string
+	// tokenExpiry is the expiry reported by the token source, zero if unknown
+	tokenExpiry time.Time
+	// loginMu serializes logins so concurrent callers share one session
+	loginMu  sync.Mutex
+	username string
+	password string
+	// tokenSource, when set, supplies the tokens instead of logins
+	tokenSource TokenSource
+	// strictDecoding rejects unknown fields in response bodies
+	strictDecoding bool
+	tlsOptions     *tlsOptions
+	retryPolicy    *RetryPolicy
+	limiter        *limiter
+	middlewares    []Middleware
+	// instrumentation middlewares wrap the user supplied ones
+	instrumentation []Middleware
+	metrics         *metrics
+	// grpcMode, when set, sends calls to the gRPC API through grpcConn
+	grpcMode  grpcMode
+	grpcConn  *grpc.ClientConn
+	grpcProxy *grpc.Server
 	// services
 	Accounts     *AccountsService
 	Sessions     *SessionsService
@@ -61,18 +89,41 @@ func (c *Client) ErrsWrapper(errs []error) error {
 	return errors.New(s)
 }
 func (c *Client) Init() (err error) {
-	if len(c.token) == 0 {
-		if token, _, err := c.Sessions.CreateUserJWT(); err == nil {
-			c.token = token.Token
-		} else {
+	return c.InitWithContext(context.Background())
+}
+func (c *Client) InitWithContext(ctx context.Context) (err error) {
+	if len(c.getToken()) == 0 {
+		if err = c.refreshToken(ctx, ""); err != nil {
 			return
 		}
 	}
-	if len(c.token) == 0 {
+	if len(c.getToken()) == 0 {
 		err = errors.New("client token is empty")
 	}
 	return
 }
+func (c *Client) getToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+func (c *Client) getTokenExpiry() (string, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token, c.tokenExpiry
+}
+func (c *Client) setToken(token string, expiry time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.tokenExpiry = expiry
+}
+
+//NewClient returns a client of the Argo CD API at baseUrl, authenticated with
+//token or else with username and password. A client created with WithGRPC or
+//WithGRPCWeb holds a gRPC connection and, for gRPC-web, an in-process proxy
+//with their goroutines until Close is called. Call Close once done with any
+//client: it is a no-op for REST clients.
 func NewClient(baseUrl, username, password, token string, options ...ClientOptionFunc) (client *Client, err error) {
 	client, err = newClient(baseUrl, username, password, token, options...)
 	if err != nil {
@@ -92,10 +143,10 @@ func newClient(baseUrl, username, password, token string, options ...ClientOptio
 		return
 	}
 	client.baseURL = baseURL
-	var harborClient *gorequest.SuperAgent
-	if harborClient == nil {
-		harborClient = gorequest.New()
-	}
+	client.UserAgent = userAgent
+	client.username = username
+	client.password = password
+	client.token = token
 	for _, fn := range options {
 		if fn == nil {
 			continue
@@ -104,15 +155,8 @@ func newClient(baseUrl, username, password, token string, options ...ClientOptio
 			return nil, err
 		}
 	}
-	client.client = harborClient
-	client.UserAgent = userAgent
-	client.username = username
-	client.password = password
-	client.token = token
-	if strings.HasPrefix(client.baseURL.String(), "https") {
-		client.client.TLSClientConfig(&tls.Config{
-			InsecureSkipVerify: true,
-		})
+	if client.httpClient, err = client.buildHTTPClient(); err != nil {
+		return nil, err
 	}
 	client.Accounts = &AccountsService{client: client}
 	client.Sessions = &SessionsService{client: client}
@@ -123,35 +167,58 @@ func newClient(baseUrl, username, password, token string, options ...ClientOptio
 	client.RepoCreds = &RepoCredsService{client: client}
 	return
 }
-func (c *Client) newRequest(method, subPath string) *gorequest.SuperAgent {
-	var u string
-	h := c.client.Set("Accept", "application/json")
-	if c.UserAgent != "" {
-		h.Set("User-Agent", c.UserAgent)
-	}
-	if len(c.token) > 0 {
-		if !strings.HasPrefix(c.token, "Bearer ") {
-			c.token = fmt.Sprintf("Bearer %s", c.token)
+
+// buildHTTPClient combines the http.Client and RoundTripper supplied through
+// options, without mutating either, into the client used for every call.
+// Server certificates are verified unless WithInsecureSkipVerify is given.
+func (c *Client) buildHTTPClient() (*http.Client, error) {
+	httpClient := &http.Client{}
+	if c.httpClient != nil {
+		*httpClient = *c.httpClient
+	}
+	if c.transport != nil {
+		httpClient.Transport = c.transport
+	}
+	if httpClient.Transport == nil {
+		httpClient.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if c.tlsOptions != nil {
+		transport, err := c.tlsOptions.applyTLS(httpClient.Transport)
+		if err != nil {
+			return nil, err
 		}
-		c.client.Set("Authorization", c.token)
-	}
-	u = c.baseURL.String() + subPath
-	switch method {
-	case gorequest.PUT:
-		return c.client.Put(u).Set("Content-Type", "application/json")
-	case gorequest.POST:
-		return c.client.Post(u).Set("Content-Type", "application/json")
-	case gorequest.GET:
-		return c.client.Get(u)
-	case gorequest.HEAD:
-		return c.client.Head(u)
-	case gorequest.DELETE:
-		return c.client.Delete(u)
-	case gorequest.PATCH:
-		return c.client.Patch(u)
-	case gorequest.OPTIONS:
-		return c.client.Options(u)
-	default:
-		return c.client.Get(u)
+		httpClient.Transport = transport
+	}
+	if c.grpcMode != grpcModeNone {
+		// gRPC-web calls go through the transport built above
+		web := *httpClient
+		transport, err := c.newGRPCTransport(&web)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = transport
+	}
+	return httpClient, nil
+}
+
+//newRequest starts a request made by operation, a service method name such
+//as ApplicationService.List
+func (c *Client) newRequest(ctx context.Context, operation, method, subPath string) *request {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	service := ""
+	if i := strings.Index(operation, "."); i >= 0 {
+		service, operation = operation[:i], operation[i+1:]
+	}
+	return &request{
+		ctx:        ctx,
+		client:     c,
+		service:    service,
+		operation:  operation,
+		method:     method,
+		path:       subPath,
+		query:      url.Values{},
+		idempotent: isIdempotent(method),
 	}
 }
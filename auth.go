@@ -0,0 +1,114 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// tokenRefreshSkew is how long before its exp claim a session token is
+// replaced, so that a request is never sent with a token about to expire.
+const tokenRefreshSkew = time.Minute
+
+//canLogin reports whether the client holds credentials or a token source to
+//obtain new tokens
+func (c *Client) canLogin() bool {
+	return c.tokenSource != nil || len(c.username) > 0 && len(c.password) > 0
+}
+
+//refreshToken obtains a new token unless another caller already replaced
+//stale. Concurrent callers holding the same stale token share a single login.
+func (c *Client) refreshToken(ctx context.Context, stale string) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+	if c.getToken() != stale {
+		return nil
+	}
+	token, err := c.fetchToken(ctx)
+	if c.metrics != nil {
+		c.metrics.tokenRefreshed(err)
+	}
+	if err != nil {
+		return err
+	}
+	c.setToken(token.AccessToken, token.Expiry)
+	return nil
+}
+
+//fetchToken asks the token source for a token, or logs in with the username
+//and password when there is none
+func (c *Client) fetchToken(ctx context.Context) (*Token, error) {
+	if c.tokenSource == nil {
+		session, _, err := c.Sessions.CreateUserJWTWithContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Token{AccessToken: session.Token}, nil
+	}
+	token, err := c.tokenSource.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("token source: %w", err)
+	}
+	if token == nil || len(token.AccessToken) == 0 {
+		return nil, errors.New("token source returned an empty token")
+	}
+	return token, nil
+}
+
+//ensureFreshToken logs in when there is no token yet or the token expires
+//within tokenRefreshSkew. Clients without credentials keep their token as is.
+func (c *Client) ensureFreshToken(ctx context.Context) error {
+	if !c.canLogin() {
+		return nil
+	}
+	token, exp := c.getTokenExpiry()
+	if len(token) > 0 {
+		ok := !exp.IsZero()
+		if !ok {
+			exp, ok = tokenExpiry(token)
+		}
+		if !ok || time.Until(exp) > tokenRefreshSkew {
+			return nil
+		}
+	}
+	return c.refreshToken(ctx, token)
+}
+
+//tokenExpiry returns the exp claim of a JWT, without verifying its signature
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
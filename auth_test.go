@@ -0,0 +1,110 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testJWT(exp time.Time) string {
+	enc := base64.RawURLEncoding
+	claims := fmt.Sprintf(`{"sub":"admin","exp":%d}`, exp.Unix())
+	return enc.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".sig"
+}
+
+// newAuthTestServer issues fresh tokens on login and rejects any other token.
+func newAuthTestServer(t *testing.T, logins, rejected *int32) *httptest.Server {
+	fresh := testJWT(time.Now().Add(time.Hour))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/session" {
+			atomic.AddInt32(logins, 1)
+			// make concurrent callers pile up behind the login
+			time.Sleep(20 * time.Millisecond)
+			_, _ = fmt.Fprintf(w, `{"token":%q}`, fresh)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+fresh {
+			atomic.AddInt32(rejected, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid session","code":16,"message":"invalid session"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestReauthenticateOnUnauthorized(t *testing.T) {
+	var logins, rejected int32
+	server := newAuthTestServer(t, &logins, &rejected)
+	client, err := NewClient(server.URL, "admin", "password", "revoked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := client.Projects.List(""); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if logins != 1 {
+		t.Fatalf("expected concurrent callers to share one login, got %d", logins)
+	}
+}
+
+func TestRefreshExpiringToken(t *testing.T) {
+	var logins, rejected int32
+	server := newAuthTestServer(t, &logins, &rejected)
+	client, err := NewClient(server.URL, "admin", "password", testJWT(time.Now().Add(10*time.Second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 || rejected != 0 {
+		t.Fatalf("expected the token to be refreshed before sending, got %d logins and %d rejections", logins, rejected)
+	}
+}
+
+func TestNoReauthenticationWithoutCredentials(t *testing.T) {
+	var logins, rejected int32
+	server := newAuthTestServer(t, &logins, &rejected)
+	client, err := NewClient(server.URL, "", "", "revoked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); !IsUnauthenticated(err) {
+		t.Fatalf("expected an unauthenticated error, got %v", err)
+	}
+	if logins != 0 {
+		t.Fatalf("expected no login without credentials, got %d", logins)
+	}
+}
@@ -17,10 +17,9 @@ limitations under the License.
 package v1
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/cluster"
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
-	"github.com/parnurzeal/gorequest"
 	"net/http"
 )
 
@@ -29,126 +28,118 @@ type ClusterService struct {
 }
 
 //List returns list of clusters
-func (s *ClusterService) List(request cluster.ClusterQuery) (result v1alpha1.ClusterList, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"clusters").
+func (s *ClusterService) List(request cluster.ClusterQuery) (result v1alpha1.ClusterList, resp *http.Response, err error) {
+	return s.ListWithContext(context.Background(), request)
+}
+
+//ListWithContext is List with a context controlling cancellation and deadlines
+func (s *ClusterService) ListWithContext(ctx context.Context, request cluster.ClusterQuery) (result v1alpha1.ClusterList, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "ClusterService.List", http.MethodGet, apiV1Prefix+"clusters").
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //Create create project
-func (s *ClusterService) Create(cluster v1alpha1.Cluster, upsert bool) (result v1alpha1.Cluster, resp gorequest.Response, err error) {
+func (s *ClusterService) Create(cluster v1alpha1.Cluster, upsert bool) (result v1alpha1.Cluster, resp *http.Response, err error) {
+	return s.CreateWithContext(context.Background(), cluster, upsert)
+}
+
+//CreateWithContext is Create with a context controlling cancellation and deadlines
+func (s *ClusterService) CreateWithContext(ctx context.Context, cluster v1alpha1.Cluster, upsert bool) (result v1alpha1.Cluster, resp *http.Response, err error) {
 	queryMap := make(map[string]bool)
 	queryMap["upsert"] = upsert
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+"clusters").
+	resp, err = s.client.
+		newRequest(ctx, "ClusterService.Create", http.MethodPost, apiV1Prefix+"clusters").
+		Attr(AttributeCluster, cluster.Server).
 		SendStruct(&cluster).
 		Query(&queryMap).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Idempotent(upsert).
+		Do(&result)
 	return
 }
 
 //Get returns a cluster by server address
-func (s *ClusterService) Get(idValue string, option cluster.ClusterQuery) (result v1alpha1.Cluster, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"clusters/"+idValue).
+func (s *ClusterService) Get(idValue string, option cluster.ClusterQuery) (result v1alpha1.Cluster, resp *http.Response, err error) {
+	return s.GetWithContext(context.Background(), idValue, option)
+}
+
+//GetWithContext is Get with a context controlling cancellation and deadlines
+func (s *ClusterService) GetWithContext(ctx context.Context, idValue string, option cluster.ClusterQuery) (result v1alpha1.Cluster, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "ClusterService.Get", http.MethodGet, apiV1Prefix+"clusters/"+idValue).
+		Attr(AttributeCluster, idValue).
 		SendStruct(&option).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //Update updates a cluster
-func (s *ClusterService) Update(idValue, idType string, updatedFields []string, cluster v1alpha1.Cluster) (result v1alpha1.Cluster, resp gorequest.Response, err error) {
+func (s *ClusterService) Update(idValue, idType string, updatedFields []string, cluster v1alpha1.Cluster) (result v1alpha1.Cluster, resp *http.Response, err error) {
+	return s.UpdateWithContext(context.Background(), idValue, idType, updatedFields, cluster)
+}
+
+//UpdateWithContext is Update with a context controlling cancellation and deadlines
+func (s *ClusterService) UpdateWithContext(ctx context.Context, idValue, idType string, updatedFields []string, cluster v1alpha1.Cluster) (result v1alpha1.Cluster, resp *http.Response, err error) {
 	sendMap := make(map[string]interface{})
 	sendMap["id.type"] = idType
 	sendMap["updatedFields"] = updatedFields
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.PUT, apiV1Prefix+"clusters/"+idValue).
+	resp, err = s.client.
+		newRequest(ctx, "ClusterService.Update", http.MethodPut, apiV1Prefix+"clusters/"+idValue).
+		Attr(AttributeCluster, idValue).
 		SendStruct(&cluster).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //Delete deletes a cluster
-func (s *ClusterService) Delete(idValue string, option cluster.ClusterQuery) (success bool, resp gorequest.Response, err error) {
+func (s *ClusterService) Delete(idValue string, option cluster.ClusterQuery) (success bool, resp *http.Response, err error) {
+	return s.DeleteWithContext(context.Background(), idValue, option)
+}
+
+//DeleteWithContext is Delete with a context controlling cancellation and deadlines
+func (s *ClusterService) DeleteWithContext(ctx context.Context, idValue string, option cluster.ClusterQuery) (success bool, resp *http.Response, err error) {
 
-	var (
-		errs []error
-	)
-	resp, _, errs = s.client.
-		newRequest(gorequest.DELETE, apiV1Prefix+"clusters/"+idValue).
+	resp, err = s.client.
+		newRequest(ctx, "ClusterService.Delete", http.MethodDelete, apiV1Prefix+"clusters/"+idValue).
+		Attr(AttributeCluster, idValue).
 		SendStruct(&option).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		success = true
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(nil)
+	success = err == nil
 	return
 }
 
 //InvalidateCache invalidates cluster cache
-func (s *ClusterService) InvalidateCache(idValue string) (success bool, resp gorequest.Response, err error) {
-
-	var (
-		errs []error
-	)
-	resp, _, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+"clusters/"+idValue+"/invalidate-cache").
-		End()
-	if resp.StatusCode == http.StatusOK {
-		success = true
-	}
-	err = s.client.ErrsWrapper(errs)
+func (s *ClusterService) InvalidateCache(idValue string) (success bool, resp *http.Response, err error) {
+	return s.InvalidateCacheWithContext(context.Background(), idValue)
+}
+
+//InvalidateCacheWithContext is InvalidateCache with a context controlling cancellation and deadlines
+func (s *ClusterService) InvalidateCacheWithContext(ctx context.Context, idValue string) (success bool, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "ClusterService.InvalidateCache", http.MethodPost, apiV1Prefix+"clusters/"+idValue+"/invalidate-cache").
+		Attr(AttributeCluster, idValue).
+		Do(nil)
+	success = err == nil
 	return
 }
 
 //RotateAuth rotates the bearer token used for a cluster
-func (s *ClusterService) RotateAuth(idValue string) (success bool, resp gorequest.Response, err error) {
-
-	var (
-		errs []error
-	)
-	resp, _, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+"clusters/"+idValue+"/rotate-auth").
-		End()
-	if resp.StatusCode == http.StatusOK {
-		success = true
-	}
-	err = s.client.ErrsWrapper(errs)
+func (s *ClusterService) RotateAuth(idValue string) (success bool, resp *http.Response, err error) {
+	return s.RotateAuthWithContext(context.Background(), idValue)
+}
+
+//RotateAuthWithContext is RotateAuth with a context controlling cancellation and deadlines
+func (s *ClusterService) RotateAuthWithContext(ctx context.Context, idValue string) (success bool, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "ClusterService.RotateAuth", http.MethodPost, apiV1Prefix+"clusters/"+idValue+"/rotate-auth").
+		Attr(AttributeCluster, idValue).
+		Do(nil)
+	success = err == nil
 	return
 }
@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/argoproj/argo-cd/v2/util/localconfig"
+)
+
+// configPathEnv overrides the location of the argocd CLI config file.
+const configPathEnv = "ARGOCD_CONFIG"
+
+//ConfigPath returns the path of the argocd CLI config file, taken from
+//ARGOCD_CONFIG when set and otherwise the file written by argocd login
+func ConfigPath() (string, error) {
+	if path := os.Getenv(configPathEnv); len(path) > 0 {
+		return path, nil
+	}
+	return localconfig.DefaultLocalConfigPath()
+}
+
+//NewClientFromConfig builds a client from a context of the argocd CLI config
+//file at path, or at ConfigPath when path is empty. An empty contextName
+//selects the current context. options are applied after the settings of the
+//context, so they take precedence. Like the argocd CLI, contexts with grpc-web
+//or a gRPC-web root path give a WithGRPCWeb client, so Close must be called
+//once done with the client.
+func NewClientFromConfig(path, contextName string, options ...ClientOptionFunc) (client *Client, err error) {
+	if len(path) == 0 {
+		if path, err = ConfigPath(); err != nil {
+			return
+		}
+	}
+	config, err := localconfig.ReadLocalConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading argocd config %s: %w", path, err)
+	}
+	if config == nil {
+		return nil, fmt.Errorf("argocd config %s does not exist", path)
+	}
+	argoContext, err := config.ResolveContext(contextName)
+	if err != nil {
+		return nil, fmt.Errorf("argocd config %s: %w", path, err)
+	}
+	server := argoContext.Server
+	if server.Core {
+		return nil, fmt.Errorf("context %s talks to Kubernetes directly, which is not supported", argoContext.Name)
+	}
+	contextOptions, err := serverOptions(server)
+	if err != nil {
+		return nil, fmt.Errorf("context %s: %w", argoContext.Name, err)
+	}
+	return NewClient(serverURL(server), "", "", argoContext.User.AuthToken, append(contextOptions, options...)...)
+}
+
+//serverURL returns the base URL of the API of a config file server
+func serverURL(server localconfig.Server) string {
+	scheme := "https"
+	if server.PlainText {
+		scheme = "http"
+	}
+	u := scheme + "://" + server.Server
+	if root := strings.Trim(server.GRPCWebRootPath, "/"); len(root) > 0 {
+		u += "/" + root
+	}
+	return u
+}
+
+//serverOptions returns the protocol and TLS options matching a config file
+//server. Like the argocd CLI, a gRPC-web root path implies gRPC-web.
+func serverOptions(server localconfig.Server) (options []ClientOptionFunc, err error) {
+	if server.GRPCWeb || len(server.GRPCWebRootPath) > 0 {
+		options = append(options, WithGRPCWeb())
+	}
+	if server.PlainText {
+		return
+	}
+	if server.Insecure {
+		options = append(options, WithInsecureSkipVerify())
+	} else if len(server.CACertificateAuthorityData) > 0 {
+		ca, err := base64.StdEncoding.DecodeString(server.CACertificateAuthorityData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding certificate-authority-data: %w", err)
+		}
+		options = append(options, WithCACertPEM(ca))
+	}
+	if len(server.ClientCertificateData) > 0 {
+		cert, err := base64.StdEncoding.DecodeString(server.ClientCertificateData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding client-certificate-data: %w", err)
+		}
+		key, err := base64.StdEncoding.DecodeString(server.ClientCertificateKeyData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding client-certificate-key-data: %w", err)
+		}
+		options = append(options, WithClientCertificatePEM(cert, key))
+	}
+	return
+}
+
+//SaveConfigContext writes the server and session token of the client to the
+//argocd CLI config file at path, or at ConfigPath when path is empty, as the
+//context name and makes it the current context, the way argocd login does.
+//An empty name defaults to the server address. A client created with a
+//username and password logs in first if it has no session yet. The config
+//file only keeps the path of gRPC-web base URLs, as their root path.
+func (c *Client) SaveConfigContext(path, name string) (err error) {
+	if len(path) == 0 {
+		if path, err = ConfigPath(); err != nil {
+			return
+		}
+	}
+	if err = c.Init(); err != nil {
+		return
+	}
+	config, err := localconfig.ReadLocalConfig(path)
+	if err != nil {
+		return fmt.Errorf("reading argocd config %s: %w", path, err)
+	}
+	if config == nil {
+		config = &localconfig.LocalConfig{}
+	}
+	address := c.baseURL.Host
+	if len(address) == 0 {
+		return errors.New("client server address is empty")
+	}
+	if len(name) == 0 {
+		name = address
+	}
+	server := localconfig.Server{Server: address}
+	if existing, err := config.GetServer(address); err == nil {
+		// keep the certificates and flags set by the CLI
+		server = *existing
+	}
+	server.PlainText = c.baseURL.Scheme == "http"
+	if c.tlsOptions != nil && c.tlsOptions.insecure {
+		server.Insecure = true
+	}
+	server.GRPCWeb = c.grpcMode == grpcModeWeb
+	server.GRPCWebRootPath = ""
+	if server.GRPCWeb {
+		// a root path alone makes the CLI use gRPC-web
+		server.GRPCWebRootPath = strings.Trim(c.baseURL.Path, "/")
+	}
+	config.UpsertServer(server)
+	config.UpsertUser(localconfig.User{Name: name, AuthToken: c.getToken()})
+	config.UpsertContext(localconfig.ContextRef{Name: name, Server: address, User: name})
+	config.CurrentContext = name
+	return localconfig.WriteLocalConfig(*config, path)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/argoproj/argo-cd/v2/util/localconfig"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	var mu sync.Mutex
+	var tokens []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	address := strings.TrimPrefix(server.URL, "https://")
+	path := writeTestConfig(t, fmt.Sprintf(`contexts:
+- name: prod
+  server: %[1]s
+  user: prod
+- name: staging
+  server: staging.example.com
+  user: staging
+current-context: prod
+servers:
+- server: %[1]s
+  certificate-authority-data: %[2]s
+- server: staging.example.com
+  insecure: true
+  grpc-web-root-path: /argocd
+users:
+- name: prod
+  auth-token: prod-token
+- name: staging
+  auth-token: staging-token
+`, address, base64.StdEncoding.EncodeToString(ca)))
+
+	client, err := NewClientFromConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0] != "Bearer prod-token" {
+		t.Fatalf("expected the current context token, got %v", tokens)
+	}
+
+	t.Setenv(configPathEnv, path)
+	staging, err := NewClientFromConfig("", "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer staging.Close()
+	if staging.baseURL.String() != "https://staging.example.com/argocd/" || !staging.tlsOptions.insecure || staging.getToken() != "staging-token" ||
+		staging.grpcMode != grpcModeWeb {
+		t.Fatalf("unexpected staging client %s", staging.baseURL)
+	}
+	if _, err = NewClientFromConfig("", "missing"); err == nil {
+		t.Fatal("expected an undefined context error")
+	}
+}
+
+func TestSaveConfigContext(t *testing.T) {
+	var logins, rejected int32
+	server := newAuthTestServer(t, &logins, &rejected)
+	path := writeTestConfig(t, `contexts:
+- name: other
+  server: other.example.com
+  user: other
+current-context: other
+servers:
+- server: other.example.com
+users:
+- name: other
+  auth-token: other-token
+`)
+	client, err := NewClient(server.URL, "admin", "password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = client.SaveConfigContext(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 {
+		t.Fatalf("expected saving the context to log in once, got %d", logins)
+	}
+	config, err := localconfig.ReadLocalConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(server.URL)
+	saved, err := config.ResolveContext("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Name != u.Host || !saved.Server.PlainText || saved.User.AuthToken != client.getToken() {
+		t.Fatalf("unexpected saved context %+v", saved)
+	}
+	if _, err = config.ResolveContext("other"); err != nil {
+		t.Fatalf("other contexts should be kept: %v", err)
+	}
+	restored, err := NewClientFromConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.baseURL.String() != server.URL+"/" || restored.getToken() != client.getToken() {
+		t.Fatalf("unexpected restored client %s", restored.baseURL)
+	}
+}
+
+func TestSaveConfigContextRoundTrip(t *testing.T) {
+	path := writeTestConfig(t, `servers:
+- server: rest.example.com
+  insecure: true
+`)
+	rest, err := NewClient("https://rest.example.com/argocd", "", "", "rest-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = rest.SaveConfigContext(path, "rest"); err != nil {
+		t.Fatal(err)
+	}
+	web, err := NewClient("https://web.example.com/argocd", "", "", "web-token", WithGRPCWeb())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer web.Close()
+	if err = web.SaveConfigContext(path, "web"); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewClientFromConfig(path, "rest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.grpcMode != grpcModeNone || restored.tlsOptions == nil || !restored.tlsOptions.insecure || restored.getToken() != "rest-token" {
+		t.Fatalf("a REST client must reload as REST with the insecure flag of the CLI, got mode %d", restored.grpcMode)
+	}
+	restoredWeb, err := NewClientFromConfig(path, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restoredWeb.Close()
+	if restoredWeb.grpcMode != grpcModeWeb || restoredWeb.baseURL.String() != "https://web.example.com/argocd/" || restoredWeb.getToken() != "web-token" {
+		t.Fatalf("unexpected gRPC-web client %s in mode %d", restoredWeb.baseURL, restoredWeb.grpcMode)
+	}
+}
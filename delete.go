@@ -0,0 +1,241 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Propagation policies of application.ApplicationDeleteRequest.
+const (
+	// PropagationForeground deletes the resources of the application before
+	// the application itself
+	PropagationForeground = "foreground"
+	// PropagationBackground deletes the application right away and its
+	// resources afterwards
+	PropagationBackground = "background"
+	// PropagationOrphan deletes the application and leaves its resources in
+	// the cluster, like a non-cascading delete
+	PropagationOrphan = "orphan"
+)
+
+// defaultDeletePollInterval is how often DeleteAndWait checks whether the
+// application is gone, besides watching it.
+const defaultDeletePollInterval = 5 * time.Second
+
+//Delete deletes an application, along with its resources unless Cascade is
+//false. PropagationPolicy is one of the Propagation constants, orphan being
+//sent as a non-cascading delete.
+func (s *ApplicationService) Delete(request application.ApplicationDeleteRequest) (success bool, resp *http.Response, err error) {
+	return s.DeleteWithContext(context.Background(), request)
+}
+
+//DeleteWithContext is Delete with a context controlling cancellation and deadlines
+func (s *ApplicationService) DeleteWithContext(ctx context.Context, request application.ApplicationDeleteRequest) (success bool, resp *http.Response, err error) {
+	if request.GetPropagationPolicy() == PropagationOrphan {
+		cascade := false
+		request.Cascade, request.PropagationPolicy = &cascade, nil
+	}
+	namespace, name := splitApplicationName(request.GetName())
+	request.Name = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.Delete", http.MethodDelete, apiV1Prefix+"applications/"+name).
+		Attr(AttributeApplication, name).
+		Query(&request).
+		Query(appNamespaceQuery(namespace)).
+		Do(nil)
+	success = err == nil
+	return
+}
+
+// DeleteWaitOptions configures ApplicationService.DeleteAndWait.
+type DeleteWaitOptions struct {
+	// Timeout bounds the wait for the application to be gone, which then
+	// fails with a *DeleteStuckError. Zero waits until ctx is done.
+	Timeout time.Duration
+	// Progress, when set, is called with the resources of the application
+	// left every time they change
+	Progress func(remaining []v1alpha1.ResourceNode)
+	// PollInterval is how often the application is checked for, besides
+	// watching it, 5s when zero
+	PollInterval time.Duration
+}
+
+// StuckResource is a resource left by an application that did not finish
+// deleting.
+type StuckResource struct {
+	Node v1alpha1.ResourceNode
+	// Finalizers are the finalizers holding the resource, when it could be
+	// fetched
+	Finalizers []string
+	// DeletionTimestamp is set once the deletion of the resource started
+	DeletionTimestamp *metav1.Time
+}
+
+// DeleteStuckError is returned by DeleteAndWait when the application was not
+// gone in time.
+type DeleteStuckError struct {
+	Application string
+	// Finalizers are the finalizers holding the application itself
+	Finalizers []string
+	Resources  []StuckResource
+}
+
+func (e *DeleteStuckError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "argocd: deletion of application %s did not complete", e.Application)
+	if len(e.Finalizers) > 0 {
+		fmt.Fprintf(&b, ", held by finalizers %v", e.Finalizers)
+	}
+	if len(e.Resources) > 0 {
+		fmt.Fprintf(&b, ", %d resources left:", len(e.Resources))
+		for _, r := range e.Resources {
+			fmt.Fprintf(&b, " %s", r.Node.FullName())
+			if len(r.Finalizers) > 0 {
+				fmt.Fprintf(&b, " (finalizers %v)", r.Finalizers)
+			}
+		}
+	}
+	return b.String()
+}
+
+//DeleteAndWait deletes an application then blocks until it is gone, following
+//the deletion of its resources. When it is not gone within the timeout of
+//options, the error is a *DeleteStuckError listing what is left.
+func (s *ApplicationService) DeleteAndWait(ctx context.Context, request application.ApplicationDeleteRequest, options DeleteWaitOptions) error {
+	if _, _, err := s.DeleteWithContext(ctx, request); err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	var waitCtx context.Context
+	var cancel context.CancelFunc
+	if options.Timeout > 0 {
+		waitCtx, cancel = context.WithTimeout(ctx, options.Timeout)
+	} else {
+		waitCtx, cancel = context.WithCancel(ctx)
+	}
+	interval := options.PollInterval
+	if interval <= 0 {
+		interval = defaultDeletePollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	name := request.GetName()
+	_, plainName := splitApplicationName(name)
+	appWatch := s.Watch(waitCtx, application.ApplicationQuery{Name: &name})
+	treeWatch := s.WatchResourceTree(waitCtx, application.ResourcesQuery{ApplicationName: &name})
+	// the watches are done by the time this returns
+	defer func() {
+		cancel()
+		for range appWatch.Events() {
+		}
+		for range treeWatch.Updates() {
+		}
+	}()
+	apps, trees := appWatch.Events(), treeWatch.Updates()
+	var (
+		app       *v1alpha1.Application
+		remaining []v1alpha1.ResourceNode
+	)
+	// the application may be gone before the watch started
+	gone := func() bool {
+		current, _, err := s.GetWithContext(waitCtx, application.ApplicationQuery{Name: &name})
+		if err == nil {
+			app = &current
+		}
+		return IsNotFound(err)
+	}
+	if gone() {
+		return nil
+	}
+	for {
+		select {
+		case event, ok := <-apps:
+			if !ok {
+				apps = nil
+				continue
+			}
+			if event.Application.Name != plainName {
+				continue
+			}
+			if event.Type == watch.Deleted {
+				return nil
+			}
+			app = &event.Application
+		case update, ok := <-trees:
+			if !ok {
+				trees = nil
+				continue
+			}
+			remaining = treeNodes(update.Tree)
+			if options.Progress != nil {
+				options.Progress(remaining)
+			}
+		case <-ticker.C:
+			if gone() {
+				return nil
+			}
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return s.deleteStuck(ctx, name, app, remaining)
+		}
+	}
+}
+
+//deleteStuck describes why the application name is not gone yet, app and
+//remaining being its last state and resources seen
+func (s *ApplicationService) deleteStuck(ctx context.Context, name string, app *v1alpha1.Application, remaining []v1alpha1.ResourceNode) error {
+	stuck := &DeleteStuckError{Application: name}
+	if app != nil {
+		stuck.Finalizers = app.Finalizers
+	}
+	for _, node := range remaining {
+		resource := StuckResource{Node: node}
+		manifest, _, err := s.GetResourceWithContext(ctx, ApplicationResourceRequest{
+			Name:         name,
+			Namespace:    node.Namespace,
+			ResourceName: node.Name,
+			Version:      node.Version,
+			Group:        node.Group,
+			Kind:         node.Kind,
+		})
+		if err == nil {
+			var object metav1.PartialObjectMetadata
+			if json.Unmarshal([]byte(manifest.GetManifest()), &object) == nil {
+				resource.Finalizers = object.Finalizers
+				resource.DeletionTimestamp = object.DeletionTimestamp
+			}
+		}
+		stuck.Resources = append(stuck.Resources, resource)
+	}
+	return stuck
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+func TestApplicationDelete(t *testing.T) {
+	var deletes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Path != "/api/v1/applications/guestbook" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		deletes = append(deletes, r.URL.RawQuery)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, namespaced, foreground, orphan := "guestbook", "team/guestbook", PropagationForeground, PropagationOrphan
+	for _, request := range []application.ApplicationDeleteRequest{
+		{Name: &name},
+		{Name: &namespaced, PropagationPolicy: &foreground},
+		{Name: &name, PropagationPolicy: &orphan},
+	} {
+		if success, _, err := client.Applications.Delete(request); !success || err != nil {
+			t.Fatalf("unexpected delete failure %v", err)
+		}
+	}
+	if fmt.Sprint(deletes) != "[name=guestbook appNamespace=team&name=guestbook&propagationPolicy=foreground cascade=false&name=guestbook]" {
+		t.Fatalf("unexpected deletes %q", deletes)
+	}
+}
+
+// deleteTreeJSON is a resource tree of the named deployments.
+func deleteTreeJSON(deployments ...string) string {
+	nodes := ""
+	for i, name := range deployments {
+		if i > 0 {
+			nodes += ","
+		}
+		nodes += fmt.Sprintf(`{"group":"apps","version":"v1","kind":"Deployment","namespace":"default","name":%q}`, name)
+	}
+	return `{"result":{"nodes":[` + nodes + `]}}` + "\n"
+}
+
+// newDeleteTestServer serves the guestbook application of namespace, empty
+// for the Argo CD namespace, until deleted is closed.
+func newDeleteTestServer(t *testing.T, namespace string, deleted <-chan struct{}) *httptest.Server {
+	const app = `{"metadata":{"name":"guestbook","finalizers":["resources-finalizer.argocd.argoproj.io"]}}`
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("appNamespace") != namespace {
+			t.Errorf("unexpected application namespace in %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/applications/guestbook":
+			if r.Method == http.MethodDelete {
+				_, _ = w.Write([]byte(`{}`))
+				return
+			}
+			_, _ = w.Write([]byte(app))
+		case "/api/v1/stream/applications":
+			_, _ = w.Write([]byte(`{"result":{"type":"MODIFIED","application":` + app + `}}` + "\n"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-deleted:
+				_, _ = w.Write([]byte(`{"result":{"type":"DELETED","application":` + app + `}}` + "\n"))
+			case <-r.Context().Done():
+			}
+		case "/api/v1/stream/applications/guestbook/resource-tree":
+			_, _ = w.Write([]byte(deleteTreeJSON("web", "worker")))
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write([]byte(deleteTreeJSON("worker")))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "/api/v1/applications/guestbook/resource":
+			_, _ = fmt.Fprintf(w, `{"manifest":%q}`, `{"metadata":{"name":"worker","finalizers":["example.com/cleanup"],"deletionTimestamp":"2022-08-01T10:00:00Z"}}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}))
+}
+
+func TestDeleteAndWait(t *testing.T) {
+	for _, namespace := range []string{"", "team"} {
+		name := "guestbook"
+		if len(namespace) > 0 {
+			name = namespace + "/" + name
+		}
+		deleted := make(chan struct{})
+		server := newDeleteTestServer(t, namespace, deleted)
+		client, err := NewClient(server.URL, "", "", "token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var progress []int
+		request := application.ApplicationDeleteRequest{Name: &name}
+		err = client.Applications.DeleteAndWait(context.Background(), request, DeleteWaitOptions{
+			Progress: func(remaining []v1alpha1.ResourceNode) {
+				progress = append(progress, len(remaining))
+				if len(remaining) == 1 {
+					close(deleted)
+				}
+			},
+		})
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(progress) != "[2 1]" {
+			t.Fatalf("unexpected progress in %q: %v", namespace, progress)
+		}
+	}
+}
+
+func TestDeleteAndWaitStuck(t *testing.T) {
+	server := newDeleteTestServer(t, "", nil)
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "guestbook"
+	err = client.Applications.DeleteAndWait(context.Background(), application.ApplicationDeleteRequest{Name: &name}, DeleteWaitOptions{
+		Timeout:      200 * time.Millisecond,
+		PollInterval: 20 * time.Millisecond,
+	})
+	var stuck *DeleteStuckError
+	if !errors.As(err, &stuck) {
+		t.Fatalf("expected the deletion to be stuck, got %v", err)
+	}
+	if fmt.Sprint(stuck.Finalizers) != "[resources-finalizer.argocd.argoproj.io]" || len(stuck.Resources) != 1 ||
+		fmt.Sprint(stuck.Resources[0].Finalizers) != "[example.com/cleanup]" || stuck.Resources[0].DeletionTimestamp == nil {
+		t.Fatalf("unexpected stuck deletion %+v", stuck)
+	}
+	want := "argocd: deletion of application guestbook did not complete, held by finalizers [resources-finalizer.argocd.argoproj.io], " +
+		"1 resources left: apps/Deployment/default/worker (finalizers [example.com/cleanup])"
+	if err.Error() != want {
+		t.Fatalf("unexpected error %q", err)
+	}
+}
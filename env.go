@@ -0,0 +1,299 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/argoproj/argo-cd/v2/util/localconfig"
+	"github.com/kballard/go-shellquote"
+)
+
+// Environment variables read by NewClientFromEnv, the same as the argocd CLI.
+const (
+	EnvServer    = "ARGOCD_SERVER"
+	EnvAuthToken = "ARGOCD_AUTH_TOKEN"
+	EnvOpts      = "ARGOCD_OPTS"
+)
+
+// SettingSource tells where NewClientFromEnv found a setting.
+type SettingSource string
+
+const (
+	SourceFlag       SettingSource = "flag"
+	SourceOpts       SettingSource = EnvOpts
+	SourceEnv        SettingSource = "environment"
+	SourceConfigFile SettingSource = "config file"
+)
+
+// boolFlags lists the argocd CLI flags taking no value. Every other flag
+// understood by the client takes one.
+var boolFlags = map[string]bool{
+	"insecure":  true,
+	"plaintext": true,
+	"grpc-web":  true,
+	"core":      true,
+}
+
+var valueFlags = map[string]bool{
+	"server":             true,
+	"auth-token":         true,
+	"grpc-web-root-path": true,
+	"header":             true,
+	"server-crt":         true,
+	"client-crt":         true,
+	"client-crt-key":     true,
+	"config":             true,
+}
+
+// EnvSettings are the connection settings resolved by LoadEnvSettings.
+type EnvSettings struct {
+	Server          string
+	AuthToken       string
+	Insecure        bool
+	PlainText       bool
+	GRPCWeb         bool
+	GRPCWebRootPath string
+	Core            bool
+	// Headers are sent with every request, in the "Name: value" form
+	Headers []string
+	// ServerCertificate is a PEM file of the certificates trusted for the server
+	ServerCertificate    string
+	ClientCertificate    string
+	ClientCertificateKey string
+	ConfigPath           string
+	// Sources maps the flag name of every setting found, like server or
+	// header, to where it was found
+	Sources map[string]SettingSource
+	// configServer is the server of the config file context the server
+	// address was taken from, carrying its certificates
+	configServer *localconfig.Server
+}
+
+//LoadEnvSettings resolves connection settings the way the argocd CLI does.
+//Each setting is taken from the first of args, holding flags like
+//--server and --insecure, ARGOCD_OPTS, the ARGOCD_SERVER and
+//ARGOCD_AUTH_TOKEN variables and the current context of the config file.
+//Flags the client has no use for, like --loglevel, are ignored.
+func LoadEnvSettings(args []string) (*EnvSettings, error) {
+	flags, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := shellquote.Split(os.Getenv(EnvOpts))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", EnvOpts, err)
+	}
+	optFlags, err := parseFlags(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", EnvOpts, err)
+	}
+	s := &EnvSettings{Sources: map[string]SettingSource{}}
+	lookup := func(name, env string) ([]string, bool) {
+		if values, ok := flags[name]; ok {
+			s.Sources[name] = SourceFlag
+			return values, true
+		}
+		if values, ok := optFlags[name]; ok {
+			s.Sources[name] = SourceOpts
+			return values, true
+		}
+		if value := os.Getenv(env); len(env) > 0 && len(value) > 0 {
+			s.Sources[name] = SourceEnv
+			return []string{value}, true
+		}
+		return nil, false
+	}
+	for name, dst := range map[string]*string{
+		"grpc-web-root-path": &s.GRPCWebRootPath,
+		"server-crt":         &s.ServerCertificate,
+		"client-crt":         &s.ClientCertificate,
+		"client-crt-key":     &s.ClientCertificateKey,
+		"config":             &s.ConfigPath,
+	} {
+		if values, ok := lookup(name, ""); ok {
+			*dst = values[len(values)-1]
+		}
+	}
+	if values, ok := lookup("server", EnvServer); ok {
+		s.Server = values[len(values)-1]
+	}
+	if values, ok := lookup("auth-token", EnvAuthToken); ok {
+		s.AuthToken = values[len(values)-1]
+	}
+	if values, ok := lookup("header", ""); ok {
+		s.Headers = values
+	}
+	for name, dst := range map[string]*bool{
+		"insecure":  &s.Insecure,
+		"plaintext": &s.PlainText,
+		"grpc-web":  &s.GRPCWeb,
+		"core":      &s.Core,
+	} {
+		if values, ok := lookup(name, ""); ok {
+			if *dst, err = strconv.ParseBool(values[len(values)-1]); err != nil {
+				return nil, fmt.Errorf("flag --%s: %w", name, err)
+			}
+		}
+	}
+	if err = s.loadConfigFile(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//loadConfigFile completes the settings with the current context of the
+//config file. The token of the context is only used for its own server.
+func (s *EnvSettings) loadConfigFile() (err error) {
+	if len(s.Server) > 0 && len(s.AuthToken) > 0 {
+		return nil
+	}
+	path := s.ConfigPath
+	if len(path) == 0 {
+		if path, err = ConfigPath(); err != nil {
+			return nil
+		}
+	}
+	config, err := localconfig.ReadLocalConfig(path)
+	if err != nil {
+		return fmt.Errorf("reading argocd config %s: %w", path, err)
+	}
+	if config == nil || len(config.CurrentContext) == 0 {
+		return nil
+	}
+	argoContext, err := config.ResolveContext("")
+	if err != nil {
+		return fmt.Errorf("argocd config %s: %w", path, err)
+	}
+	server := argoContext.Server
+	if len(s.Server) == 0 {
+		s.Server = server.Server
+		s.configServer = &server
+		s.Sources["server"] = SourceConfigFile
+		for name, flag := range map[string]struct {
+			dst   *bool
+			value bool
+		}{
+			"insecure":  {&s.Insecure, server.Insecure},
+			"plaintext": {&s.PlainText, server.PlainText},
+			"grpc-web":  {&s.GRPCWeb, server.GRPCWeb},
+			"core":      {&s.Core, server.Core},
+		} {
+			if _, ok := s.Sources[name]; !ok && flag.value {
+				*flag.dst = true
+				s.Sources[name] = SourceConfigFile
+			}
+		}
+		if _, ok := s.Sources["grpc-web-root-path"]; !ok && len(server.GRPCWebRootPath) > 0 {
+			s.GRPCWebRootPath = server.GRPCWebRootPath
+			s.Sources["grpc-web-root-path"] = SourceConfigFile
+		}
+	}
+	if len(s.AuthToken) == 0 && s.Server == server.Server && len(argoContext.User.AuthToken) > 0 {
+		s.AuthToken = argoContext.User.AuthToken
+		s.Sources["auth-token"] = SourceConfigFile
+	}
+	return nil
+}
+
+//parseFlags parses flags given as --name value, --name=value or -H value,
+//collecting the values of repeated flags
+func parseFlags(args []string) (map[string][]string, error) {
+	flags := map[string][]string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var name string
+		switch {
+		case arg == "-H":
+			name = "header"
+		case strings.HasPrefix(arg, "--") && len(arg) > 2:
+			name = arg[2:]
+		default:
+			return nil, fmt.Errorf("invalid option at %q", arg)
+		}
+		value, hasValue := "", false
+		if j := strings.Index(name, "="); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+		if !hasValue {
+			switch {
+			case boolFlags[name]:
+				value = "true"
+			case i+1 < len(args) && !strings.HasPrefix(args[i+1], "-"):
+				i++
+				value = args[i]
+			case valueFlags[name]:
+				return nil, fmt.Errorf("flag --%s needs a value", name)
+			}
+		}
+		flags[name] = append(flags[name], value)
+	}
+	return flags, nil
+}
+
+//NewClientFromEnv builds a client from the settings resolved by
+//LoadEnvSettings, returned alongside it to report where each one came from.
+//options are applied after the resolved settings, so they take precedence.
+//--grpc-web, --grpc-web-root-path and their environment variables give a
+//WithGRPCWeb client, so Close must be called once done with the client.
+func NewClientFromEnv(args []string, options ...ClientOptionFunc) (client *Client, settings *EnvSettings, err error) {
+	if settings, err = LoadEnvSettings(args); err != nil {
+		return
+	}
+	if len(settings.Server) == 0 {
+		return nil, settings, errors.New("no Argo CD server given through --server, " + EnvServer + " or the argocd config file")
+	}
+	if settings.Core {
+		return nil, settings, errors.New("--core talks to Kubernetes directly, which is not supported")
+	}
+	server := localconfig.Server{}
+	if settings.configServer != nil {
+		server = *settings.configServer
+	}
+	server.Server = settings.Server
+	server.Insecure = settings.Insecure
+	server.PlainText = settings.PlainText
+	server.GRPCWeb = settings.GRPCWeb
+	server.GRPCWebRootPath = settings.GRPCWebRootPath
+	if i := strings.Index(server.Server, "://"); i >= 0 {
+		server.PlainText = server.PlainText || server.Server[:i] == "http"
+		server.Server = server.Server[i+3:]
+	}
+	settingOptions, err := serverOptions(server)
+	if err != nil {
+		return nil, settings, err
+	}
+	if len(settings.ServerCertificate) > 0 && !server.Insecure && !server.PlainText {
+		settingOptions = append(settingOptions, WithCACertFile(settings.ServerCertificate))
+	}
+	if len(settings.ClientCertificate) > 0 {
+		settingOptions = append(settingOptions, WithClientCertificateFile(settings.ClientCertificate, settings.ClientCertificateKey))
+	}
+	for _, header := range settings.Headers {
+		i := strings.Index(header, ":")
+		if i <= 0 {
+			return nil, settings, fmt.Errorf("header %q is not in the Name: value form", header)
+		}
+		settingOptions = append(settingOptions, WithHeader(strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:])))
+	}
+	client, err = NewClient(serverURL(server), "", "", settings.AuthToken, append(settingOptions, options...)...)
+	return
+}
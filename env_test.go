@@ -0,0 +1,136 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearArgoEnv isolates a test from the environment of the machine.
+func clearArgoEnv(t *testing.T) {
+	t.Setenv(EnvServer, "")
+	t.Setenv(EnvAuthToken, "")
+	t.Setenv(EnvOpts, "")
+	t.Setenv(configPathEnv, filepath.Join(t.TempDir(), "config"))
+}
+
+func TestNewClientFromEnv(t *testing.T) {
+	clearArgoEnv(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/projects" || r.Header.Get("X-Tenant") != "team-a" ||
+			r.Header.Get("Authorization") != "Bearer ci-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+	t.Setenv(EnvServer, "ignored.example.com")
+	t.Setenv(EnvAuthToken, "ci-token")
+	t.Setenv(EnvOpts, "--plaintext --loglevel debug --header 'X-Tenant: team-a' --server=ignored.example.com")
+	client, settings, err := NewClientFromEnv([]string{
+		"--server", strings.TrimPrefix(server.URL, "http://"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]SettingSource{
+		"server":     SourceFlag,
+		"auth-token": SourceEnv,
+		"plaintext":  SourceOpts,
+		"header":     SourceOpts,
+	}
+	if !reflect.DeepEqual(settings.Sources, want) {
+		t.Fatalf("unexpected sources %v", settings.Sources)
+	}
+
+	// like the argocd CLI, a gRPC-web root path implies gRPC-web
+	web, _, err := NewClientFromEnv([]string{"--grpc-web-root-path", "/argocd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer web.Close()
+	if web.grpcMode != grpcModeWeb || web.baseURL.Path != "/argocd/" {
+		t.Fatalf("expected a gRPC-web client under /argocd, got %s", web.baseURL)
+	}
+}
+
+func TestNewClientFromEnvConfigFile(t *testing.T) {
+	clearArgoEnv(t)
+	path := writeTestConfig(t, `contexts:
+- name: prod
+  server: prod.example.com
+  user: prod
+current-context: prod
+servers:
+- server: prod.example.com
+  insecure: true
+users:
+- name: prod
+  auth-token: prod-token
+`)
+	t.Setenv(EnvOpts, fmt.Sprintf("--config %s", path))
+	client, settings, err := NewClientFromEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.baseURL.String() != "https://prod.example.com/" || !client.tlsOptions.insecure || client.getToken() != "prod-token" {
+		t.Fatalf("unexpected client %s", client.baseURL)
+	}
+	if settings.Sources["server"] != SourceConfigFile || settings.Sources["insecure"] != SourceConfigFile ||
+		settings.Sources["auth-token"] != SourceConfigFile || settings.Sources["config"] != SourceOpts {
+		t.Fatalf("unexpected sources %v", settings.Sources)
+	}
+
+	// the token of the context is not sent to another server
+	if _, settings, err = NewClientFromEnv([]string{"--server", "other.example.com", "--config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.AuthToken) > 0 || settings.Insecure {
+		t.Fatalf("unexpected settings %+v", settings)
+	}
+}
+
+func TestNewClientFromEnvErrors(t *testing.T) {
+	clearArgoEnv(t)
+	if _, _, err := NewClientFromEnv(nil); err == nil {
+		t.Fatal("expected a missing server error")
+	}
+	for _, args := range [][]string{
+		{"server"},
+		{"--server"},
+		{"--server", "argocd.example.com", "--insecure=maybe"},
+		{"--server", "argocd.example.com", "-H", "no-colon"},
+	} {
+		if _, _, err := NewClientFromEnv(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+	t.Setenv(EnvOpts, "--header 'unterminated")
+	if _, err := LoadEnvSettings(nil); err == nil {
+		t.Fatal("expected an ARGOCD_OPTS error")
+	}
+}
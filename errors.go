@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// APIError is returned by every service method when the Argo CD API server
+// answers with a non-2xx status. Code, Message and Err are decoded from the
+// gRPC-gateway error body when the server sent one.
+type APIError struct {
+	StatusCode int        `json:"-"`
+	Code       codes.Code `json:"code"`
+	Message    string     `json:"message"`
+	Err        string     `json:"error"`
+	// Body is the raw response body, kept for bodies that are not gateway errors
+	Body string `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if len(msg) == 0 {
+		msg = e.Err
+	}
+	if len(msg) == 0 {
+		msg = e.Body
+	}
+	if len(msg) == 0 {
+		msg = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("argocd: %d %s", e.StatusCode, msg)
+}
+
+func newAPIError(statusCode int, body []byte) *APIError {
+	e := &APIError{StatusCode: statusCode}
+	if json.Unmarshal(body, e) != nil {
+		e.Code, e.Message, e.Err = codes.Unknown, "", ""
+	}
+	e.Body = string(body)
+	return e
+}
+
+// hasCode reports whether err is an APIError with the given gRPC code, falling
+// back to the HTTP status for servers that did not send a gateway error body.
+func hasCode(err error, code codes.Code, statusCode int) bool {
+	var e *APIError
+	if !errors.As(err, &e) {
+		return false
+	}
+	if e.Code != codes.OK && e.Code != codes.Unknown {
+		return e.Code == code
+	}
+	return e.StatusCode == statusCode
+}
+
+const (
+	// maxSnippetLen bounds the body excerpt carried by a DecodeError
+	maxSnippetLen = 256
+	// unknownFieldPrefix starts the error encoding/json reports for unknown fields
+	unknownFieldPrefix = `json: unknown field "`
+)
+
+// DecodeError is returned when a successful response body cannot be decoded
+// into the expected type, which usually means the Argo CD server speaks a
+// different API version than the one this client was built against.
+type DecodeError struct {
+	StatusCode int
+	// Field is the dotted path of the offending field, empty when unknown
+	Field string
+	// Snippet is the start of the response body, truncated to maxSnippetLen
+	Snippet string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	if len(e.Field) > 0 {
+		return fmt.Sprintf("argocd: decoding field %q: %v; body: %s", e.Field, e.Err, e.Snippet)
+	}
+	return fmt.Sprintf("argocd: decoding response: %v; body: %s", e.Err, e.Snippet)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func newDecodeError(statusCode int, body []byte, err error) *DecodeError {
+	e := &DecodeError{StatusCode: statusCode, Err: err}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		e.Field = typeErr.Field
+	} else if msg := err.Error(); strings.HasPrefix(msg, unknownFieldPrefix) {
+		// DisallowUnknownFields only reports the field name
+		e.Field = strings.TrimSuffix(strings.TrimPrefix(msg, unknownFieldPrefix), `"`)
+	}
+	if len(body) > maxSnippetLen {
+		e.Snippet = string(body[:maxSnippetLen]) + "..."
+	} else {
+		e.Snippet = string(body)
+	}
+	return e
+}
+
+//IsDecodeError reports whether err is a failure to decode a response body
+func IsDecodeError(err error) bool {
+	var e *DecodeError
+	return errors.As(err, &e)
+}
+
+//IsNotFound reports whether err means the requested resource does not exist
+func IsNotFound(err error) bool {
+	return hasCode(err, codes.NotFound, http.StatusNotFound)
+}
+
+//IsPermissionDenied reports whether err means the caller lacks permission
+func IsPermissionDenied(err error) bool {
+	return hasCode(err, codes.PermissionDenied, http.StatusForbidden)
+}
+
+//IsAlreadyExists reports whether err means the resource already exists
+func IsAlreadyExists(err error) bool {
+	return hasCode(err, codes.AlreadyExists, http.StatusConflict)
+}
+
+//IsUnauthenticated reports whether err means the session is missing or expired
+func IsUnauthenticated(err error) bool {
+	return hasCode(err, codes.Unauthenticated, http.StatusUnauthorized)
+}
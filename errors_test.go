@@ -0,0 +1,106 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestAPIErrorFromGatewayBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"app not found","code":5,"message":"app not found"}`))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = client.Projects.Get("missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != codes.NotFound || apiErr.Message != "app not found" {
+		t.Fatalf("unexpected error fields: %+v", apiErr)
+	}
+	if !IsNotFound(err) || IsPermissionDenied(err) {
+		t.Fatalf("unexpected classification of %v", err)
+	}
+}
+
+func TestAPIErrorWithoutGatewayBody(t *testing.T) {
+	err := error(newAPIError(http.StatusForbidden, []byte("<html>forbidden</html>")))
+	if !IsPermissionDenied(err) {
+		t.Fatalf("expected permission denied, got %v", err)
+	}
+	if IsNotFound(err) || IsAlreadyExists(err) || IsUnauthenticated(err) {
+		t.Fatalf("unexpected classification of %v", err)
+	}
+	if IsNotFound(errors.New("plain")) {
+		t.Fatal("plain errors are not API errors")
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	body := `{"items":[{"metadata":{"name":5}}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = client.Projects.List("")
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Fatalf("expected *DecodeError, got %v", err)
+	}
+	if !strings.HasSuffix(decodeErr.Field, "metadata.name") || decodeErr.Snippet != body {
+		t.Fatalf("unexpected error fields: %+v", decodeErr)
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"items":[],"addedInNextRelease":true}`))
+	}))
+	defer server.Close()
+	lenient, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = lenient.Projects.List(""); err != nil {
+		t.Fatalf("unknown fields should be ignored by default: %v", err)
+	}
+	strict, err := NewClient(server.URL, "", "", "token", WithStrictDecoding())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = strict.Projects.List("")
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Field != "addedInNextRelease" {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
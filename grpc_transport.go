@@ -0,0 +1,534 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/account"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/cluster"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/project"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/repocreds"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/repository"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/session"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/version"
+	grpcutil "github.com/argoproj/argo-cd/v2/util/grpc"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcMode selects the protocol calls are sent with.
+type grpcMode int
+
+const (
+	grpcModeNone grpcMode = iota
+	grpcModeNative
+	grpcModeWeb
+)
+
+const (
+	// maxGRPCMessageSize matches the limit of the argocd CLI
+	maxGRPCMessageSize = 100 * 1024 * 1024
+	grpcFrameHeaderLen = 5
+	grpcTrailerFlag    = 0x80
+)
+
+//WithGRPC sends calls to the Argo CD gRPC API instead of its REST gateway,
+//over the TLS settings of the client or in plain text for http base URLs.
+//Service methods keep their signatures: the REST gateway of Argo CD runs in
+//process and translates every call. WithTransport and WithHTTPClient do not
+//apply to gRPC connections. The connection is held until Close.
+func WithGRPC() ClientOptionFunc {
+	return func(c *Client) error {
+		c.grpcMode = grpcModeNative
+		return nil
+	}
+}
+
+//WithGRPCWeb sends calls to the Argo CD gRPC API using the gRPC-web protocol,
+//for servers behind proxies that only pass HTTP/1.1. The path of the base URL
+//is the gRPC-web root path, and requests go through the HTTP transport of the
+//client. The in-process proxy translating calls runs until Close.
+func WithGRPCWeb() ClientOptionFunc {
+	return func(c *Client) error {
+		c.grpcMode = grpcModeWeb
+		return nil
+	}
+}
+
+// grpcTransport serves the REST requests of the client with the Argo CD REST
+// gateway, which forwards them to the gRPC connection.
+type grpcTransport struct {
+	mux *runtime.ServeMux
+	// root is the path of the base URL, stripped from request paths
+	root string
+}
+
+//newGRPCTransport connects to the server, through httpClient in gRPC-web mode,
+//and registers the gateway of every service
+func (c *Client) newGRPCTransport(httpClient *http.Client) (*grpcTransport, error) {
+	dialOptions := []grpc.DialOption{
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxGRPCMessageSize), grpc.MaxCallSendMsgSize(maxGRPCMessageSize)),
+		grpc.WithUserAgent(c.UserAgent),
+	}
+	target := c.baseURL.Host
+	switch c.grpcMode {
+	case grpcModeNative:
+		if c.baseURL.Port() == "" {
+			if c.baseURL.Scheme == "http" {
+				target += ":80"
+			} else {
+				target += ":443"
+			}
+		}
+		if c.baseURL.Scheme == "http" {
+			dialOptions = append(dialOptions, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		} else {
+			tlsConfig := &tls.Config{}
+			if c.tlsOptions != nil {
+				var err error
+				if tlsConfig, err = c.tlsOptions.config(); err != nil {
+					return nil, err
+				}
+			}
+			dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+		}
+	case grpcModeWeb:
+		listener := newMemListener()
+		c.grpcProxy = c.newGRPCWebProxy(httpClient)
+		go func() {
+			_ = c.grpcProxy.Serve(listener)
+		}()
+		target = "grpc-web-proxy"
+		dialOptions = append(dialOptions,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return listener.DialContext(ctx)
+			}))
+	}
+	conn, err := grpc.Dial(target, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+	c.grpcConn = conn
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, new(grpcutil.JSONMarshaler)),
+		runtime.WithIncomingHeaderMatcher(grpcHeaderMatcher),
+	)
+	ctx := context.Background()
+	for _, register := range []func(context.Context, *runtime.ServeMux, *grpc.ClientConn) error{
+		account.RegisterAccountServiceHandler,
+		session.RegisterSessionServiceHandler,
+		application.RegisterApplicationServiceHandler,
+		cluster.RegisterClusterServiceHandler,
+		project.RegisterProjectServiceHandler,
+		repository.RegisterRepositoryServiceHandler,
+		repocreds.RegisterRepoCredsServiceHandler,
+		version.RegisterVersionServiceHandler,
+	} {
+		if err = register(ctx, mux, conn); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return &grpcTransport{mux: mux, root: strings.TrimSuffix(c.baseURL.Path, "/")}, nil
+}
+
+//grpcHeaderMatcher forwards the headers added through WithHeader and
+//middlewares, like trace context, as gRPC metadata
+func grpcHeaderMatcher(key string) (string, bool) {
+	if k, ok := runtime.DefaultHeaderMatcher(key); ok {
+		return k, true
+	}
+	switch key {
+	case "Accept-Encoding", "Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade":
+		return "", false
+	}
+	return strings.ToLower(key), true
+}
+
+//Close releases the gRPC connection of clients created with WithGRPC or
+//WithGRPCWeb, stopping the in-process proxy of gRPC-web. Without it both leak
+//their goroutines. Other clients have nothing to release. The client must not
+//be used after Close.
+func (c *Client) Close() error {
+	if c.grpcProxy != nil {
+		c.grpcProxy.Stop()
+	}
+	if c.grpcConn != nil {
+		return c.grpcConn.Close()
+	}
+	return nil
+}
+
+func (t *grpcTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	inner := req.Clone(ctx)
+	inner.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, t.root), "/")
+	inner.URL.RawPath = ""
+	inner.RequestURI = inner.URL.RequestURI()
+	if inner.Body == nil {
+		inner.Body = http.NoBody
+	}
+	reader, writer := io.Pipe()
+	w := &pipeResponseWriter{
+		header: http.Header{},
+		body:   writer,
+		ready:  make(chan struct{}),
+		resp: &http.Response{
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			ContentLength: -1,
+			Request:       req,
+			Body:          &cancelReadCloser{ReadCloser: reader, cancel: cancel},
+		},
+	}
+	go func() {
+		t.mux.ServeHTTP(w, inner)
+		w.WriteHeader(http.StatusOK)
+		_ = writer.Close()
+	}()
+	select {
+	case <-w.ready:
+		return w.resp, nil
+	case <-ctx.Done():
+		cancel()
+		_ = reader.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// pipeResponseWriter streams the response written by the gateway to the
+// body of the *http.Response returned once the status is known.
+type pipeResponseWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+	resp   *http.Response
+	once   sync.Once
+	ready  chan struct{}
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(statusCode int) {
+	w.once.Do(func() {
+		w.resp.StatusCode = statusCode
+		w.resp.Status = strconv.Itoa(statusCode) + " " + http.StatusText(statusCode)
+		w.resp.Header = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+//Flush is a no-op, writes reach the reader as they happen
+func (w *pipeResponseWriter) Flush() {}
+
+// cancelReadCloser cancels the gRPC call when the body is closed early.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// rawCodec passes the serialized messages through the gRPC-web proxy.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return v.([]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*[]byte)) = data
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+//newGRPCWebProxy returns an in-memory gRPC server relaying every call to
+//the Argo CD server with the gRPC-web protocol
+func (c *Client) newGRPCWebProxy(httpClient *http.Client) *grpc.Server {
+	return grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+			method, ok := grpc.MethodFromServerStream(stream)
+			if !ok {
+				return status.Error(codes.Internal, "no method name in stream")
+			}
+			var msg []byte
+			if err := stream.RecvMsg(&msg); err != nil {
+				return err
+			}
+			resp, err := c.sendGRPCWeb(stream.Context(), httpClient, method, msg)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return relayGRPCWebFrames(resp.Body, stream)
+		}))
+}
+
+//sendGRPCWeb posts a gRPC-web request for method, carrying the metadata of
+//the call as headers
+func (c *Client) sendGRPCWeb(ctx context.Context, httpClient *http.Client, method string, msg []byte) (*http.Response, error) {
+	frame := make([]byte, grpcFrameHeaderLen, grpcFrameHeaderLen+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	frame = append(frame, msg...)
+	u := strings.TrimSuffix(c.baseURL.String(), "/") + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(frame))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vs := range md {
+		if strings.HasPrefix(k, ":") || strings.HasPrefix(k, "grpc-") || k == "content-type" {
+			continue
+		}
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/grpc-web+proto")
+	req.Header.Set("X-Grpc-Web", "1")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, status.Errorf(httpStatusCode(resp.StatusCode), "gRPC-web call %s failed with status %d", method, resp.StatusCode)
+	}
+	if err = grpcStatus(resp.Header); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+//relayGRPCWebFrames sends the messages of a gRPC-web response to stream until
+//the trailer frame, returning the status it carries
+func relayGRPCWebFrames(body io.Reader, stream grpc.ServerStream) error {
+	for {
+		header := make([]byte, grpcFrameHeaderLen)
+		if _, err := io.ReadFull(body, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				// trailers-only responses carry the status in headers
+				return nil
+			}
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		if _, err := io.ReadFull(body, data); err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		if header[0]&grpcTrailerFlag != 0 {
+			trailer, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(data, "\r\n"...)))).ReadMIMEHeader()
+			if err != nil && !errors.Is(err, io.EOF) {
+				return status.Error(codes.Internal, err.Error())
+			}
+			return grpcStatus(http.Header(trailer))
+		}
+		if err := stream.SendMsg(data); err != nil {
+			return err
+		}
+	}
+}
+
+//grpcStatus returns the error of the grpc-status in header, nil when OK or
+//absent
+func grpcStatus(header http.Header) error {
+	value := header.Get("Grpc-Status")
+	if len(value) == 0 {
+		return nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return status.Errorf(codes.Unknown, "invalid grpc-status %q", value)
+	}
+	if codes.Code(code) == codes.OK {
+		return nil
+	}
+	return status.Error(codes.Code(code), header.Get("Grpc-Message"))
+}
+
+//httpStatusCode maps the HTTP status of a failed gRPC-web call to a gRPC code
+func httpStatusCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+	return codes.Unknown
+}
+
+// memListener is an in-memory net.Listener connecting the client connection
+// to the gRPC-web proxy.
+type memListener struct {
+	conns  chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newMemListener() *memListener {
+	return &memListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *memListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *memListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *memListener) Addr() net.Addr {
+	return memAddr{}
+}
+
+//DialContext returns the client end of a connection whose server end is
+//handed to Accept
+func (l *memListener) DialContext(ctx context.Context) (net.Conn, error) {
+	toServer, toClient := newMemPipe(), newMemPipe()
+	select {
+	case l.conns <- &memConn{r: toServer, w: toClient}:
+		return &memConn{r: toClient, w: toServer}, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// memPipe is one direction of a memConn. Writes never block, so both ends
+// can write their HTTP/2 preface at the same time; gRPC flow control bounds
+// the buffered data.
+type memPipe struct {
+	mu     sync.Mutex
+	cond   sync.Cond
+	buf    bytes.Buffer
+	closed bool
+}
+
+func newMemPipe() *memPipe {
+	p := &memPipe{}
+	p.cond.L = &p.mu
+	return p
+}
+
+func (p *memPipe) read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.buf.Len() == 0 {
+		if p.closed {
+			return 0, io.EOF
+		}
+		p.cond.Wait()
+	}
+	return p.buf.Read(b)
+}
+
+func (p *memPipe) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	p.cond.Broadcast()
+	return p.buf.Write(b)
+}
+
+func (p *memPipe) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.cond.Broadcast()
+}
+
+// memConn is an end of an in-memory connection. Deadlines are accepted and
+// ignored: gRPC only sets them around the handshake, which cannot stall in
+// memory.
+type memConn struct {
+	r, w *memPipe
+}
+
+func (c *memConn) Read(b []byte) (int, error) {
+	return c.r.read(b)
+}
+
+func (c *memConn) Write(b []byte) (int, error) {
+	return c.w.write(b)
+}
+
+func (c *memConn) Close() error {
+	c.r.close()
+	c.w.close()
+	return nil
+}
+
+func (c *memConn) LocalAddr() net.Addr              { return memAddr{} }
+func (c *memConn) RemoteAddr() net.Addr             { return memAddr{} }
+func (c *memConn) SetDeadline(time.Time) error      { return nil }
+func (c *memConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *memConn) SetWriteDeadline(time.Time) error { return nil }
+
+// memAddr is the address of both ends of a memConn.
+type memAddr struct{}
+
+func (memAddr) Network() string { return "memory" }
+func (memAddr) String() string  { return "grpc-web-proxy" }
@@ -0,0 +1,145 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/project"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/improbable-eng/grpc-web/go/grpcweb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// testProjectServer lists a single project to callers presenting the token
+// and tenant header, and knows no other project.
+type testProjectServer struct {
+	project.UnimplementedProjectServiceServer
+}
+
+func (s *testProjectServer) List(ctx context.Context, q *project.ProjectQuery) (*v1alpha1.AppProjectList, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if auth := md.Get("authorization"); len(auth) != 1 || auth[0] != "Bearer token" {
+		return nil, status.Error(codes.Unauthenticated, "invalid session")
+	}
+	if tenant := md.Get("x-tenant"); len(tenant) != 1 || tenant[0] != "team-a" {
+		return nil, status.Error(codes.PermissionDenied, "unknown tenant")
+	}
+	return &v1alpha1.AppProjectList{Items: []v1alpha1.AppProject{{ObjectMeta: metav1.ObjectMeta{Name: "default"}}}}, nil
+}
+
+func (s *testProjectServer) Delete(ctx context.Context, q *project.ProjectQuery) (*project.EmptyResponse, error) {
+	return nil, status.Errorf(codes.NotFound, "project %s not found", q.Name)
+}
+
+// testApplicationServer streams two events to watchers, then waits for the
+// watcher to go away.
+type testApplicationServer struct {
+	application.UnimplementedApplicationServiceServer
+}
+
+func (s *testApplicationServer) Watch(q *application.ApplicationQuery, stream application.ApplicationService_WatchServer) error {
+	for _, rv := range []string{"1", "2"} {
+		event := &v1alpha1.ApplicationWatchEvent{Type: watch.Modified}
+		event.Application.Name, event.Application.ResourceVersion = q.GetName(), rv
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func newTestGRPCServer(t *testing.T) *grpc.Server {
+	server := grpc.NewServer()
+	project.RegisterProjectServiceServer(server, &testProjectServer{})
+	application.RegisterApplicationServiceServer(server, &testApplicationServer{})
+	t.Cleanup(server.Stop)
+	return server
+}
+
+func testGRPCCalls(t *testing.T, client *Client) {
+	t.Cleanup(func() { _ = client.Close() })
+	list, _, err := client.Projects.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "default" {
+		t.Fatalf("unexpected projects %+v", list.Items)
+	}
+	if _, _, err = client.Projects.Delete("missing"); !IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	name := "guestbook"
+	events := client.Applications.Watch(ctx, application.ApplicationQuery{Name: &name}).Events()
+	for _, rv := range []string{"1", "2"} {
+		if event := <-events; event.Application.Name != name || event.Application.ResourceVersion != rv {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	}
+}
+
+func TestGRPCTransport(t *testing.T) {
+	server := newTestGRPCServer(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	client, err := NewClient("http://"+listener.Addr().String(), "", "", "token", WithGRPC(), WithHeader("X-Tenant", "team-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGRPCCalls(t, client)
+}
+
+func TestGRPCWebTransport(t *testing.T) {
+	wrapped := grpcweb.WrapServer(newTestGRPCServer(t))
+	var requests int
+	web := httptest.NewServer(http.StripPrefix("/argocd", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !wrapped.IsGrpcWebRequest(r) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		requests++
+		wrapped.ServeHTTP(w, r)
+	})))
+	defer web.Close()
+	client, err := NewClient(web.URL+"/argocd", "", "", "token", WithGRPCWeb(), WithHeader("X-Tenant", "team-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGRPCCalls(t, client)
+	if requests != 3 {
+		t.Fatalf("expected 3 gRPC-web requests, got %d", requests)
+	}
+}
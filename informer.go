@@ -0,0 +1,364 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Indexes maintained by an ApplicationInformer, queried with ByIndex.
+const (
+	// IndexProject indexes applications by project
+	IndexProject = "project"
+	// IndexCluster indexes applications by destination server URL and name
+	IndexCluster = "cluster"
+	// IndexNamespace indexes applications by destination namespace
+	IndexNamespace = "namespace"
+	// IndexRepoURL indexes applications by source repository URL
+	IndexRepoURL = "repoURL"
+	// IndexLabel indexes applications by label, as key=value
+	IndexLabel = "label"
+)
+
+var applicationIndexers = map[string]func(app *v1alpha1.Application) []string{
+	IndexProject: func(app *v1alpha1.Application) []string {
+		return []string{app.Spec.Project}
+	},
+	IndexCluster: func(app *v1alpha1.Application) []string {
+		return []string{app.Spec.Destination.Server, app.Spec.Destination.Name}
+	},
+	IndexNamespace: func(app *v1alpha1.Application) []string {
+		return []string{app.Spec.Destination.Namespace}
+	},
+	IndexRepoURL: func(app *v1alpha1.Application) []string {
+		return []string{app.Spec.Source.RepoURL}
+	},
+	IndexLabel: func(app *v1alpha1.Application) []string {
+		values := make([]string, 0, len(app.Labels))
+		for k, v := range app.Labels {
+			values = append(values, k+"="+v)
+		}
+		return values
+	},
+}
+
+// ApplicationHandler is notified of the changes seen by an
+// ApplicationInformer. Any of the funcs may be nil. They are called one at a
+// time, in order, and must not block for long.
+type ApplicationHandler struct {
+	OnAdd    func(app *v1alpha1.Application)
+	OnUpdate func(old, new *v1alpha1.Application)
+	OnDelete func(app *v1alpha1.Application)
+}
+
+// ApplicationInformerOptions configures an ApplicationInformer.
+type ApplicationInformerOptions struct {
+	// Query filters the applications cached, by projects or selector
+	Query application.ApplicationQuery
+	// ResyncPeriod is how often applications are listed again, catching up
+	// on events a broken stream missed and notifying OnUpdate for every
+	// application. Zero disables resyncs.
+	ResyncPeriod time.Duration
+}
+
+// ApplicationInformer keeps an indexed in-memory copy of the applications,
+// listed once then kept up to date from the application stream, for many
+// readers to share. Applications returned by its methods are copies.
+type ApplicationInformer struct {
+	client  *Client
+	options ApplicationInformerOptions
+
+	mu       sync.RWMutex
+	items    map[string]*v1alpha1.Application
+	indices  map[string]map[string]map[string]bool
+	handlers []ApplicationHandler
+	synced   chan struct{}
+	// dispatchMu keeps notifications in order across handlers added late
+	dispatchMu sync.Mutex
+}
+
+//NewApplicationInformer returns an informer caching the applications client
+//can see. It starts filling once Run is called.
+func NewApplicationInformer(client *Client, options ApplicationInformerOptions) *ApplicationInformer {
+	return &ApplicationInformer{
+		client:  client,
+		options: options,
+		items:   map[string]*v1alpha1.Application{},
+		indices: map[string]map[string]map[string]bool{},
+		synced:  make(chan struct{}),
+	}
+}
+
+//AddHandler registers handler. Applications already cached are notified to
+//its OnAdd first.
+func (i *ApplicationInformer) AddHandler(handler ApplicationHandler) {
+	i.dispatchMu.Lock()
+	defer i.dispatchMu.Unlock()
+	i.mu.Lock()
+	i.handlers = append(i.handlers, handler)
+	i.mu.Unlock()
+	if handler.OnAdd != nil {
+		for _, app := range i.List() {
+			app := app
+			handler.OnAdd(&app)
+		}
+	}
+}
+
+//Run fills the cache and keeps it up to date until ctx is done, returning
+//the context error, or until the server refuses to list or stream the
+//applications.
+func (i *ApplicationInformer) Run(ctx context.Context) error {
+	resync := false
+	for {
+		list, err := i.list(ctx)
+		if err != nil {
+			return err
+		}
+		i.replace(list.Items, resync)
+		resync = true
+
+		var watchCtx context.Context
+		var cancel context.CancelFunc
+		if i.options.ResyncPeriod > 0 {
+			watchCtx, cancel = context.WithTimeout(ctx, i.options.ResyncPeriod)
+		} else {
+			watchCtx, cancel = context.WithCancel(ctx)
+		}
+		query := i.options.Query
+		query.ResourceVersion = &list.ResourceVersion
+		w := i.client.Applications.Watch(watchCtx, query)
+		for event := range w.Events() {
+			i.apply(event)
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err = w.Err(); !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+	}
+}
+
+//list lists the applications, backing off while the server is unavailable
+func (i *ApplicationInformer) list(ctx context.Context) (v1alpha1.ApplicationList, error) {
+	for failures := 1; ; failures++ {
+		list, _, err := i.client.Applications.ListWithContext(ctx, i.options.Query)
+		if ctx.Err() != nil {
+			return list, ctx.Err()
+		}
+		if err == nil || !reconnectable(err) {
+			return list, err
+		}
+		if err = sleep(ctx, streamReconnect.backoff(failures, nil)); err != nil {
+			return list, err
+		}
+	}
+}
+
+//HasSynced reports whether the applications were listed at least once
+func (i *ApplicationInformer) HasSynced() bool {
+	select {
+	case <-i.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+//WaitForSync blocks until the applications were listed once or ctx is done
+func (i *ApplicationInformer) WaitForSync(ctx context.Context) error {
+	select {
+	case <-i.synced:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//Get returns the cached application named name
+func (i *ApplicationInformer) Get(name string) (*v1alpha1.Application, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	app, ok := i.items[name]
+	if !ok {
+		return nil, false
+	}
+	return app.DeepCopy(), true
+}
+
+//List returns the cached applications sorted by name
+func (i *ApplicationInformer) List() []v1alpha1.Application {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	names := make([]string, 0, len(i.items))
+	for name := range i.items {
+		names = append(names, name)
+	}
+	return i.copies(names)
+}
+
+//ByIndex returns the cached applications whose index, one of the Index
+//constants, has value, sorted by name
+func (i *ApplicationInformer) ByIndex(index, value string) []v1alpha1.Application {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	names := make([]string, 0, len(i.indices[index][value]))
+	for name := range i.indices[index][value] {
+		names = append(names, name)
+	}
+	return i.copies(names)
+}
+
+//copies returns copies of the named applications sorted by name, with i.mu
+//held
+func (i *ApplicationInformer) copies(names []string) []v1alpha1.Application {
+	sort.Strings(names)
+	apps := make([]v1alpha1.Application, 0, len(names))
+	for _, name := range names {
+		apps = append(apps, *i.items[name].DeepCopy())
+	}
+	return apps
+}
+
+// informerChange is a change of the cache to notify handlers of.
+type informerChange struct {
+	old, new *v1alpha1.Application
+}
+
+//replace makes apps the content of the cache, notifying OnUpdate for every
+//application kept when resyncing
+func (i *ApplicationInformer) replace(apps []v1alpha1.Application, resync bool) {
+	i.dispatchMu.Lock()
+	defer i.dispatchMu.Unlock()
+	i.mu.Lock()
+	var changes []informerChange
+	seen := map[string]bool{}
+	for n := range apps {
+		app := &apps[n]
+		seen[app.Name] = true
+		if old, ok := i.store(app); !ok || resync || old.ResourceVersion != app.ResourceVersion {
+			changes = append(changes, informerChange{old: old, new: app})
+		}
+	}
+	for name, old := range i.items {
+		if !seen[name] {
+			i.remove(old)
+			changes = append(changes, informerChange{old: old})
+		}
+	}
+	handlers := i.handlers
+	i.mu.Unlock()
+	select {
+	case <-i.synced:
+	default:
+		close(i.synced)
+	}
+	notify(handlers, changes)
+}
+
+//apply updates the cache with a watch event
+func (i *ApplicationInformer) apply(event v1alpha1.ApplicationWatchEvent) {
+	i.dispatchMu.Lock()
+	defer i.dispatchMu.Unlock()
+	i.mu.Lock()
+	app := event.Application.DeepCopy()
+	var change informerChange
+	if event.Type == watch.Deleted {
+		old, ok := i.items[app.Name]
+		if !ok {
+			i.mu.Unlock()
+			return
+		}
+		i.remove(old)
+		change.old = old
+	} else {
+		change.old, _ = i.store(app)
+		change.new = app
+	}
+	handlers := i.handlers
+	i.mu.Unlock()
+	notify(handlers, []informerChange{change})
+}
+
+//store caches app, returning the application it replaced
+func (i *ApplicationInformer) store(app *v1alpha1.Application) (*v1alpha1.Application, bool) {
+	old, ok := i.items[app.Name]
+	if ok {
+		i.remove(old)
+	}
+	i.items[app.Name] = app
+	for index, indexer := range applicationIndexers {
+		if i.indices[index] == nil {
+			i.indices[index] = map[string]map[string]bool{}
+		}
+		for _, value := range indexer(app) {
+			if len(value) == 0 {
+				continue
+			}
+			if i.indices[index][value] == nil {
+				i.indices[index][value] = map[string]bool{}
+			}
+			i.indices[index][value][app.Name] = true
+		}
+	}
+	return old, ok
+}
+
+//remove drops app from the cache and its indices
+func (i *ApplicationInformer) remove(app *v1alpha1.Application) {
+	delete(i.items, app.Name)
+	for index, indexer := range applicationIndexers {
+		for _, value := range indexer(app) {
+			delete(i.indices[index][value], app.Name)
+			if len(i.indices[index][value]) == 0 {
+				delete(i.indices[index], value)
+			}
+		}
+	}
+}
+
+//notify calls the handlers for every change, each with its own copies
+func notify(handlers []ApplicationHandler, changes []informerChange) {
+	for _, change := range changes {
+		for _, h := range handlers {
+			switch {
+			case change.new == nil:
+				if h.OnDelete != nil {
+					h.OnDelete(change.old.DeepCopy())
+				}
+			case change.old == nil:
+				if h.OnAdd != nil {
+					h.OnAdd(change.new.DeepCopy())
+				}
+			default:
+				if h.OnUpdate != nil {
+					h.OnUpdate(change.old.DeepCopy(), change.new.DeepCopy())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+func informerAppJSON(name, project, resourceVersion string) string {
+	return fmt.Sprintf(`{"metadata":{"name":%q,"resourceVersion":%q,"labels":{"team":"web"}},`+
+		`"spec":{"project":%q,"source":{"repoURL":"https://github.com/argoproj/argocd-example-apps"},`+
+		`"destination":{"server":"https://kubernetes.default.svc","namespace":%q}}}`,
+		name, resourceVersion, project, name)
+}
+
+func TestApplicationInformer(t *testing.T) {
+	fastStreamReconnect(t)
+	var lists int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/applications":
+			if atomic.AddInt32(&lists, 1) == 1 {
+				_, _ = fmt.Fprintf(w, `{"metadata":{"resourceVersion":"10"},"items":[%s,%s]}`,
+					informerAppJSON("guestbook", "default", "5"), informerAppJSON("helm", "default", "6"))
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"metadata":{"resourceVersion":"20"},"items":[%s]}`,
+				informerAppJSON("kustomize", "team", "12"))
+		case "/api/v1/stream/applications":
+			switch r.URL.Query().Get("resourceVersion") {
+			case "10":
+				for _, event := range []string{
+					fmt.Sprintf(`{"result":{"type":"MODIFIED","application":%s}}`, informerAppJSON("guestbook", "staging", "11")),
+					fmt.Sprintf(`{"result":{"type":"ADDED","application":%s}}`, informerAppJSON("kustomize", "team", "12")),
+					fmt.Sprintf(`{"result":{"type":"DELETED","application":%s}}`, informerAppJSON("helm", "default", "13")),
+				} {
+					_, _ = w.Write([]byte(event + "\n"))
+				}
+				w.(http.Flusher).Flush()
+			case "20":
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	informer := NewApplicationInformer(client, ApplicationInformerOptions{ResyncPeriod: 200 * time.Millisecond})
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(format string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, fmt.Sprintf(format, args...))
+	}
+	informer.AddHandler(ApplicationHandler{
+		OnAdd: func(app *v1alpha1.Application) { record("add %s", app.Name) },
+		OnUpdate: func(old, new *v1alpha1.Application) {
+			record("update %s %s->%s", new.Name, old.ResourceVersion, new.ResourceVersion)
+		},
+		OnDelete: func(app *v1alpha1.Application) { record("delete %s", app.Name) },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- informer.Run(ctx) }()
+	if err := informer.WaitForSync(ctx); err != nil || !informer.HasSynced() {
+		t.Fatalf("expected the informer to sync, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&lists) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the informer to resync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// a late handler is told about what is cached
+	var late []string
+	informer.AddHandler(ApplicationHandler{OnAdd: func(app *v1alpha1.Application) { late = append(late, app.Name) }})
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected the informer to stop canceled, got %v", err)
+	}
+
+	mu.Lock()
+	got := fmt.Sprint(events)
+	mu.Unlock()
+	want := "[add guestbook add helm update guestbook 5->11 add kustomize delete helm update kustomize 12->12 delete guestbook]"
+	if got != want {
+		t.Fatalf("unexpected events\n got %s\nwant %s", got, want)
+	}
+	if fmt.Sprint(late) != "[kustomize]" {
+		t.Fatalf("unexpected late adds %v", late)
+	}
+	if _, ok := informer.Get("guestbook"); ok {
+		t.Fatal("expected guestbook to be gone after the resync")
+	}
+	app, ok := informer.Get("kustomize")
+	if !ok || app.Spec.Project != "team" {
+		t.Fatalf("unexpected application %+v", app)
+	}
+	for index, value := range map[string]string{
+		IndexProject:   "team",
+		IndexCluster:   "https://kubernetes.default.svc",
+		IndexNamespace: "kustomize",
+		IndexRepoURL:   "https://github.com/argoproj/argocd-example-apps",
+		IndexLabel:     "team=web",
+	} {
+		if apps := informer.ByIndex(index, value); len(apps) != 1 || apps[0].Name != "kustomize" {
+			t.Fatalf("unexpected applications for %s=%s: %v", index, value, apps)
+		}
+	}
+	if apps := informer.ByIndex(IndexProject, "default"); len(apps) != 0 {
+		t.Fatalf("expected no application left in default, got %v", apps)
+	}
+}
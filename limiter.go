@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// LimiterStats reports how much the client throttled its calls. Every round
+// trip, including retries and logins, counts as one call.
+type LimiterStats struct {
+	// Calls is the number of calls that went through the limiter
+	Calls int64
+	// WaitTime is the total time calls spent waiting for a token or a slot
+	WaitTime time.Duration
+	// InFlight is the number of calls currently holding a slot
+	InFlight int64
+}
+
+// limiter caps the rate and the concurrency of the calls made by a Client,
+// shared by all of its services.
+type limiter struct {
+	rate  *rate.Limiter
+	slots chan struct{}
+	// counters, accessed atomically
+	calls, waitNanos, inFlight int64
+}
+
+func (c *Client) limiterOrNew() *limiter {
+	if c.limiter == nil {
+		c.limiter = &limiter{}
+	}
+	return c.limiter
+}
+
+//WithRateLimit limits the client to qps calls per second on average, with
+//bursts of up to burst calls
+func WithRateLimit(qps float64, burst int) ClientOptionFunc {
+	return func(c *Client) error {
+		if qps <= 0 || burst <= 0 {
+			return errors.New("rate limit qps and burst must be positive")
+		}
+		c.limiterOrNew().rate = rate.NewLimiter(rate.Limit(qps), burst)
+		return nil
+	}
+}
+
+//WithMaxInFlight limits the number of calls the client runs concurrently
+func WithMaxInFlight(n int) ClientOptionFunc {
+	return func(c *Client) error {
+		if n <= 0 {
+			return errors.New("max in-flight calls must be positive")
+		}
+		c.limiterOrNew().slots = make(chan struct{}, n)
+		return nil
+	}
+}
+
+//acquire blocks until the call may proceed, returning the function releasing
+//its slot. The time waited is recorded in m when the client has metrics.
+func (l *limiter) acquire(ctx context.Context, m *metrics) (release func(), err error) {
+	atomic.AddInt64(&l.calls, 1)
+	start := time.Now()
+	defer func() {
+		waited := time.Since(start)
+		atomic.AddInt64(&l.waitNanos, int64(waited))
+		if m != nil {
+			m.limiterWait.Observe(waited.Seconds())
+		}
+	}()
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if l.rate != nil {
+		if err = l.rate.Wait(ctx); err != nil {
+			l.releaseSlot()
+			return nil, err
+		}
+	}
+	atomic.AddInt64(&l.inFlight, 1)
+	return func() {
+		atomic.AddInt64(&l.inFlight, -1)
+		l.releaseSlot()
+	}, nil
+}
+
+func (l *limiter) releaseSlot() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+//LimiterStats returns how much the rate limit and in-flight cap throttled
+//the client so far
+func (c *Client) LimiterStats() LimiterStats {
+	l := c.limiter
+	if l == nil {
+		return LimiterStats{}
+	}
+	return LimiterStats{
+		Calls:    atomic.LoadInt64(&l.calls),
+		WaitTime: time.Duration(atomic.LoadInt64(&l.waitNanos)),
+		InFlight: atomic.LoadInt64(&l.inFlight),
+	}
+}
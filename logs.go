@@ -0,0 +1,437 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+// LogLine is a line of container logs.
+type LogLine struct {
+	Content   string
+	TimeStamp time.Time
+	PodName   string
+	// Container is the container logging, when it was selected
+	Container string
+}
+
+// LogStream delivers the lines of ApplicationService.StreamPodLogs.
+type LogStream struct {
+	lines chan LogLine
+	err   error
+}
+
+//Lines returns the channel of log lines, closed when the logs end
+func (s *LogStream) Lines() <-chan LogLine {
+	return s.lines
+}
+
+//Err returns why the logs ended, nil when the server sent them all and the
+//context error when it was canceled. It is only valid once Lines is closed.
+func (s *LogStream) Err() error {
+	return s.err
+}
+
+//StreamPodLogs streams the logs of the pod named in query, or of the pods of
+//the resource selected by its kind, group and resourceName, honoring its
+//container, previous, sinceSeconds, sinceTime, tailLines, filter and follow
+//fields. Following logs go on until ctx is done.
+func (s *ApplicationService) StreamPodLogs(ctx context.Context, query application.ApplicationPodLogsQuery) *LogStream {
+	stream := &LogStream{lines: make(chan LogLine)}
+	go func() {
+		defer close(stream.lines)
+		stream.err = s.streamPodLogs(ctx, query, stream.lines)
+		if ctx.Err() != nil {
+			stream.err = ctx.Err()
+		}
+	}()
+	return stream
+}
+
+//streamPodLogs forwards the lines of the logs stream until its last entry
+func (s *ApplicationService) streamPodLogs(ctx context.Context, query application.ApplicationPodLogsQuery, lines chan<- LogLine) error {
+	namespace, name := splitApplicationName(*query.Name)
+	query.Name = &name
+	path := apiV1Prefix + "applications/" + name + "/logs"
+	if query.PodName != nil && len(*query.PodName) > 0 {
+		path = apiV1Prefix + "applications/" + name + "/pods/" + *query.PodName + "/logs"
+	}
+	resp, err := s.client.
+		newRequest(ctx, "ApplicationService.PodLogs", http.MethodGet, path).
+		Attr(AttributeApplication, name).
+		Query(&query).
+		Query(appNamespaceQuery(namespace)).
+		Stream()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var entry application.LogEntry
+		if err = s.client.readStreamMessage(dec, &entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if entry.Last != nil && *entry.Last {
+			return nil
+		}
+		line := LogLine{Content: entry.GetContent(), PodName: entry.GetPodName(), Container: query.GetContainer()}
+		if entry.TimeStamp != nil {
+			line.TimeStamp = entry.TimeStamp.Time
+		}
+		select {
+		case lines <- line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//PodLogsReader is StreamPodLogs as a reader of the log contents, one line
+//per entry. Reading ends with io.EOF once the logs end, or with the error
+//that broke the stream. Closing the reader stops the stream.
+func (s *ApplicationService) PodLogsReader(ctx context.Context, query application.ApplicationPodLogsQuery) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	r, w := io.Pipe()
+	go func() {
+		defer cancel()
+		stream := s.StreamPodLogs(ctx, query)
+		for line := range stream.Lines() {
+			if _, err := io.WriteString(w, line.Content+"\n"); err != nil {
+				cancel()
+			}
+		}
+		w.CloseWithError(stream.Err())
+	}()
+	return &logsReader{PipeReader: r, cancel: cancel}
+}
+
+// logsReader stops the logs stream it reads when closed.
+type logsReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+//Close stops the stream and the reads in progress
+func (r *logsReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// defaultMergeWindow is how long TailLogs holds lines to order them.
+const defaultMergeWindow = 250 * time.Millisecond
+
+// TailOptions configures ApplicationService.TailLogs.
+type TailOptions struct {
+	// Containers restricts the containers tailed, all of them when empty
+	Containers []string
+	// Include keeps only the lines matching it, when set
+	Include *regexp.Regexp
+	// Exclude drops the lines matching it, when set
+	Exclude      *regexp.Regexp
+	SinceSeconds *int64
+	TailLines    *int64
+	// Follow keeps tailing, pods created later included, until ctx is done
+	Follow bool
+	// MergeWindow is how long lines are held back to be merged in timestamp
+	// order with the lines of other pods, 250ms when zero
+	MergeWindow time.Duration
+}
+
+//TailLogs streams the logs of every container of every pod in the resource
+//tree of the application name, merged in timestamp order. When following,
+//the pods appearing in the tree later are tailed too.
+func (s *ApplicationService) TailLogs(ctx context.Context, name string, options TailOptions) *LogStream {
+	stream := &LogStream{lines: make(chan LogLine)}
+	go func() {
+		defer close(stream.lines)
+		stream.err = s.tailLogs(ctx, name, options, stream.lines)
+		if ctx.Err() != nil {
+			stream.err = ctx.Err()
+		}
+	}()
+	return stream
+}
+
+//TailLogsReader is TailLogs as a reader of the log contents, one line per
+//entry prefixed with its pod and container. Closing the reader stops the
+//tail.
+func (s *ApplicationService) TailLogsReader(ctx context.Context, name string, options TailOptions) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	r, w := io.Pipe()
+	go func() {
+		defer cancel()
+		stream := s.TailLogs(ctx, name, options)
+		for line := range stream.Lines() {
+			if _, err := io.WriteString(w, line.PodName+"/"+line.Container+": "+line.Content+"\n"); err != nil {
+				cancel()
+			}
+		}
+		w.CloseWithError(stream.Err())
+	}()
+	return &logsReader{PipeReader: r, cancel: cancel}
+}
+
+// podLogsEnd reports the end of the logs of a container.
+type podLogsEnd struct {
+	pod v1alpha1.ResourceNode
+	err error
+}
+
+//tailLogs discovers the pods of the application and merges their logs into
+//out until they all ended
+func (s *ApplicationService) tailLogs(ctx context.Context, name string, options TailOptions, out chan<- LogLine) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	window := options.MergeWindow
+	if window <= 0 {
+		window = defaultMergeWindow
+	}
+	pods := make(chan v1alpha1.ResourceNode)
+	discovery := make(chan error, 1)
+	go func() {
+		defer close(pods)
+		discovery <- s.discoverPods(ctx, name, options.Follow, pods)
+	}()
+
+	lines := make(chan LogLine)
+	ends := make(chan podLogsEnd)
+	tailed := map[string]bool{}
+	running := 0
+	buffer := &logBuffer{}
+	ticker := time.NewTicker(window / 2)
+	defer ticker.Stop()
+	for pods != nil || running > 0 {
+		select {
+		case pod, ok := <-pods:
+			if !ok {
+				pods = nil
+				if err := <-discovery; err != nil {
+					return err
+				}
+				continue
+			}
+			if tailed[pod.FullName()] {
+				continue
+			}
+			tailed[pod.FullName()] = true
+			containers, err := s.podContainers(ctx, name, pod, options.Containers)
+			if IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			for _, container := range containers {
+				running++
+				wg.Add(1)
+				go func(pod v1alpha1.ResourceNode, container string) {
+					defer wg.Done()
+					err := s.forwardPodLogs(ctx, name, pod, container, options, lines)
+					select {
+					case ends <- podLogsEnd{pod: pod, err: err}:
+					case <-ctx.Done():
+					}
+				}(pod, container)
+			}
+		case line := <-lines:
+			if options.Include != nil && !options.Include.MatchString(line.Content) ||
+				options.Exclude != nil && options.Exclude.MatchString(line.Content) {
+				continue
+			}
+			buffer.add(line)
+		case end := <-ends:
+			running--
+			// the logs of a pod deleted meanwhile end with it
+			if end.err != nil && !IsNotFound(end.err) {
+				return end.err
+			}
+		case <-ticker.C:
+			if err := buffer.flush(ctx, time.Now().Add(-window), out); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return buffer.flush(ctx, time.Now(), out)
+}
+
+//discoverPods sends the pods of the resource tree of the application name,
+//then those added to it while following
+func (s *ApplicationService) discoverPods(ctx context.Context, name string, follow bool, pods chan<- v1alpha1.ResourceNode) error {
+	send := func(nodes []v1alpha1.ResourceNode) error {
+		for _, n := range nodes {
+			if n.Group != "" || n.Kind != "Pod" {
+				continue
+			}
+			select {
+			case pods <- n:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+	query := application.ResourcesQuery{ApplicationName: &name}
+	if !follow {
+		tree, _, err := s.ResourceTreeWithContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		return send(tree.Nodes)
+	}
+	watch := s.WatchResourceTree(ctx, query)
+	for update := range watch.Updates() {
+		if err := send(update.Added); err != nil {
+			return err
+		}
+	}
+	return watch.Err()
+}
+
+//podContainers returns the names of the containers of pod, among selected
+//when any
+func (s *ApplicationService) podContainers(ctx context.Context, name string, pod v1alpha1.ResourceNode, selected []string) ([]string, error) {
+	resource, _, err := s.GetResourceWithContext(ctx, ApplicationResourceRequest{
+		Name:         name,
+		Namespace:    pod.Namespace,
+		ResourceName: pod.Name,
+		Version:      "v1",
+		Kind:         "Pod",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Spec struct {
+			Containers []struct {
+				Name string `json:"name"`
+			} `json:"containers"`
+		} `json:"spec"`
+	}
+	if err = json.Unmarshal([]byte(resource.GetManifest()), &manifest); err != nil {
+		return nil, newDecodeError(http.StatusOK, []byte(resource.GetManifest()), err)
+	}
+	var containers []string
+	for _, c := range manifest.Spec.Containers {
+		if len(selected) == 0 {
+			containers = append(containers, c.Name)
+			continue
+		}
+		for _, want := range selected {
+			if want == c.Name {
+				containers = append(containers, c.Name)
+				break
+			}
+		}
+	}
+	return containers, nil
+}
+
+//forwardPodLogs sends the logs of a container of pod to lines
+func (s *ApplicationService) forwardPodLogs(ctx context.Context, name string, pod v1alpha1.ResourceNode, container string, options TailOptions, lines chan<- LogLine) error {
+	query := application.ApplicationPodLogsQuery{
+		Name:         &name,
+		Namespace:    &pod.Namespace,
+		PodName:      &pod.Name,
+		Container:    &container,
+		SinceSeconds: options.SinceSeconds,
+		TailLines:    options.TailLines,
+		Follow:       &options.Follow,
+	}
+	stream := s.StreamPodLogs(ctx, query)
+	for line := range stream.Lines() {
+		if len(line.PodName) == 0 {
+			line.PodName = pod.Name
+		}
+		select {
+		case lines <- line:
+		case <-ctx.Done():
+		}
+	}
+	return stream.Err()
+}
+
+// bufferedLine is a log line waiting in a logBuffer.
+type bufferedLine struct {
+	line     LogLine
+	received time.Time
+	seq      int
+}
+
+// logBuffer is a heap of log lines ordered by timestamp, then arrival.
+type logBuffer struct {
+	lines []bufferedLine
+	seq   int
+}
+
+func (b *logBuffer) Len() int { return len(b.lines) }
+
+func (b *logBuffer) Less(i, j int) bool {
+	if !b.lines[i].line.TimeStamp.Equal(b.lines[j].line.TimeStamp) {
+		return b.lines[i].line.TimeStamp.Before(b.lines[j].line.TimeStamp)
+	}
+	return b.lines[i].seq < b.lines[j].seq
+}
+
+func (b *logBuffer) Swap(i, j int) { b.lines[i], b.lines[j] = b.lines[j], b.lines[i] }
+
+func (b *logBuffer) Push(x interface{}) { b.lines = append(b.lines, x.(bufferedLine)) }
+
+func (b *logBuffer) Pop() interface{} {
+	last := b.lines[len(b.lines)-1]
+	b.lines = b.lines[:len(b.lines)-1]
+	return last
+}
+
+//add buffers line
+func (b *logBuffer) add(line LogLine) {
+	b.seq++
+	heap.Push(b, bufferedLine{line: line, received: time.Now(), seq: b.seq})
+}
+
+//flush sends to out, in order, the lines until the first received after
+//before
+func (b *logBuffer) flush(ctx context.Context, before time.Time, out chan<- LogLine) error {
+	for b.Len() > 0 && !b.lines[0].received.After(before) {
+		select {
+		case out <- heap.Pop(b).(bufferedLine).line:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
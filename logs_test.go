@@ -0,0 +1,247 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+)
+
+func logEntryJSON(pod, content string) string {
+	return fmt.Sprintf(`{"result":{"content":%q,"timeStamp":"2022-08-01T10:00:00Z","podName":%q}}`+"\n", content, pod)
+}
+
+func newLogsTestServer(t *testing.T, follow bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v1/applications/guestbook/pods/web-1/logs" || q.Get("container") != "app" ||
+			q.Get("tailLines") != "10" || q.Get("sinceSeconds") != "60" || q.Get("previous") != "true" {
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(logEntryJSON("web-1", "starting")))
+		_, _ = w.Write([]byte(logEntryJSON("web-1", "listening on :8080")))
+		if follow {
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":{"content":"","timeStamp":"0001-01-01T00:00:00Z","last":true}}` + "\n"))
+	}))
+}
+
+func testLogsQuery(follow bool) application.ApplicationPodLogsQuery {
+	name, pod, container := "guestbook", "web-1", "app"
+	tail, since, previous := int64(10), int64(60), true
+	return application.ApplicationPodLogsQuery{
+		Name: &name, PodName: &pod, Container: &container,
+		TailLines: &tail, SinceSeconds: &since, Previous: &previous, Follow: &follow,
+	}
+}
+
+func TestStreamPodLogs(t *testing.T) {
+	server := newLogsTestServer(t, false)
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := client.Applications.StreamPodLogs(context.Background(), testLogsQuery(false))
+	var got []string
+	for line := range stream.Lines() {
+		got = append(got, fmt.Sprintf("%s %s %s", line.PodName, line.TimeStamp.Format("15:04"), line.Content))
+	}
+	if stream.Err() != nil {
+		t.Fatal(stream.Err())
+	}
+	if fmt.Sprint(got) != "[web-1 10:00 starting web-1 10:00 listening on :8080]" {
+		t.Fatalf("unexpected lines %v", got)
+	}
+}
+
+func TestStreamPodLogsFollowCanceled(t *testing.T) {
+	server := newLogsTestServer(t, true)
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := client.Applications.StreamPodLogs(ctx, testLogsQuery(true))
+	<-stream.Lines()
+	<-stream.Lines()
+	cancel()
+	for range stream.Lines() {
+	}
+	if stream.Err() != context.Canceled {
+		t.Fatalf("expected the logs to end canceled, got %v", stream.Err())
+	}
+}
+
+func TestPodLogsReader(t *testing.T) {
+	server := newLogsTestServer(t, false)
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := client.Applications.PodLogsReader(context.Background(), testLogsQuery(false))
+	defer reader.Close()
+	logs, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(logs) != "starting\nlistening on :8080\n" {
+		t.Fatalf("unexpected logs %q", logs)
+	}
+
+	server = newLogsTestServer(t, true)
+	defer server.Close()
+	client, err = NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader = client.Applications.PodLogsReader(context.Background(), testLogsQuery(true))
+	buf := make([]byte, len("starting\n"))
+	if _, err = io.ReadFull(reader, buf); err != nil || string(buf) != "starting\n" {
+		t.Fatalf("unexpected read %q: %v", buf, err)
+	}
+	if err = reader.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = reader.Read(buf); err != io.ErrClosedPipe {
+		t.Fatalf("expected reads to fail once closed, got %v", err)
+	}
+}
+
+// tailTestLogs are the logs of the tail test pods, by pod/container.
+var tailTestLogs = map[string][][2]string{
+	"web-1/app":     {{"10:00:01", "GET /"}, {"10:00:04", "GET /healthz"}, {"10:00:05", "GET /cart"}},
+	"web-1/sidecar": {{"10:00:02", "proxy ready"}},
+	"web-2/app":     {{"10:00:03", "GET /login"}},
+}
+
+func newTailTestServer(t *testing.T) *httptest.Server {
+	pod := func(name string) string {
+		return fmt.Sprintf(`{"version":"v1","kind":"Pod","namespace":"default","name":%q}`, name)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/api/v1/applications/guestbook/resource-tree":
+			_, _ = fmt.Fprintf(w, `{"nodes":[{"group":"apps","version":"v1","kind":"Deployment","namespace":"default","name":"web"},%s,%s]}`,
+				pod("web-1"), pod("web-2"))
+		case "/api/v1/stream/applications/guestbook/resource-tree":
+			_, _ = fmt.Fprintf(w, `{"result":{"nodes":[%s]}}`+"\n", pod("web-1"))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+			_, _ = fmt.Fprintf(w, `{"result":{"nodes":[%s,%s]}}`+"\n", pod("web-1"), pod("web-2"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "/api/v1/applications/guestbook/resource":
+			containers := `[{"name":"app"}]`
+			if q.Get("resourceName") == "web-1" {
+				containers = `[{"name":"app"},{"name":"sidecar"}]`
+			}
+			_, _ = fmt.Fprintf(w, `{"manifest":%q}`, `{"spec":{"containers":`+containers+`}}`)
+		case "/api/v1/applications/guestbook/pods/web-1/logs", "/api/v1/applications/guestbook/pods/web-2/logs":
+			podName := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v1/applications/guestbook/pods/"), "/logs")
+			for _, entry := range tailTestLogs[podName+"/"+q.Get("container")] {
+				_, _ = fmt.Fprintf(w, `{"result":{"content":%q,"timeStamp":"2022-08-01T%sZ","podName":%q}}`+"\n",
+					entry[1], entry[0], podName)
+			}
+			if q.Get("follow") == "true" {
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			}
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestTailLogs(t *testing.T) {
+	server := newTailTestServer(t)
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := client.Applications.TailLogsReader(context.Background(), "guestbook", TailOptions{
+		Include: regexp.MustCompile(`^GET|ready`),
+		Exclude: regexp.MustCompile(`healthz`),
+		// the logs end before the window, merging them all
+		MergeWindow: time.Minute,
+	})
+	defer reader.Close()
+	logs, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "web-1/app: GET /\nweb-1/sidecar: proxy ready\nweb-2/app: GET /login\nweb-1/app: GET /cart\n"
+	if string(logs) != want {
+		t.Fatalf("unexpected logs\n%s", logs)
+	}
+
+	stream := client.Applications.TailLogs(context.Background(), "guestbook", TailOptions{Containers: []string{"sidecar"}})
+	var got []string
+	for line := range stream.Lines() {
+		got = append(got, line.PodName+"/"+line.Container)
+	}
+	if stream.Err() != nil || fmt.Sprint(got) != "[web-1/sidecar]" {
+		t.Fatalf("unexpected lines %v: %v", got, stream.Err())
+	}
+}
+
+func TestTailLogsFollow(t *testing.T) {
+	server := newTailTestServer(t)
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := client.Applications.TailLogs(ctx, "guestbook", TailOptions{Follow: true, MergeWindow: 20 * time.Millisecond})
+	// web-2 appears in the tree after web-1
+	var got []string
+	for line := range stream.Lines() {
+		got = append(got, line.PodName+" "+line.Content)
+		if len(got) == 5 {
+			cancel()
+		}
+	}
+	if stream.Err() != context.Canceled {
+		t.Fatalf("expected the tail to end canceled, got %v", stream.Err())
+	}
+	sort.Strings(got)
+	if fmt.Sprint(got) != "[web-1 GET / web-1 GET /cart web-1 GET /healthz web-1 proxy ready web-2 GET /login]" {
+		t.Fatalf("unexpected lines %v", got)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "argocd_client"
+
+// metrics holds the collectors of a Client. Clients registering on the same
+// Registerer share them.
+type metrics struct {
+	requests        *prometheus.CounterVec
+	errors          *prometheus.CounterVec
+	duration        *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	tokenRefreshes  *prometheus.CounterVec
+	lastRefreshTime prometheus.Gauge
+	limiterWait     prometheus.Histogram
+}
+
+//WithMetrics registers Prometheus collectors on registerer counting the calls
+//of the client, labeled by service, operation and status class, along with
+//gauges of the calls in flight and of the last session token refresh. With
+//WithRateLimit or WithMaxInFlight, a histogram records how long every call
+//waited for the limiter.
+func WithMetrics(registerer prometheus.Registerer) ClientOptionFunc {
+	return func(c *Client) error {
+		if registerer == nil {
+			return errors.New("metrics registerer is nil")
+		}
+		m, err := newMetrics(registerer)
+		if err != nil {
+			return err
+		}
+		c.metrics = m
+		c.instrumentation = append(c.instrumentation, m.middleware)
+		return nil
+	}
+}
+
+func newMetrics(registerer prometheus.Registerer) (m *metrics, err error) {
+	m = &metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Number of calls made to the Argo CD API.",
+		}, []string{"service", "operation", "status_class"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_errors_total",
+			Help:      "Number of calls to the Argo CD API that returned an error.",
+		}, []string{"service", "operation", "status_class"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of calls to the Argo CD API, retries included.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "operation", "status_class"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "in_flight_requests",
+			Help:      "Number of calls to the Argo CD API in progress.",
+		}),
+		tokenRefreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_refreshes_total",
+			Help:      "Number of session token refreshes, by result.",
+		}, []string{"result"}),
+		lastRefreshTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_last_refresh_timestamp_seconds",
+			Help:      "Time of the last successful session token refresh.",
+		}),
+		limiterWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "limiter_wait_seconds",
+			Help:      "Time calls to the Argo CD API waited for a rate limit token or an in-flight slot.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+	if err = register(registerer, &m.requests); err != nil {
+		return nil, err
+	}
+	if err = register(registerer, &m.errors); err != nil {
+		return nil, err
+	}
+	if err = register(registerer, &m.duration); err != nil {
+		return nil, err
+	}
+	if err = register(registerer, &m.inFlight); err != nil {
+		return nil, err
+	}
+	if err = register(registerer, &m.tokenRefreshes); err != nil {
+		return nil, err
+	}
+	if err = register(registerer, &m.lastRefreshTime); err != nil {
+		return nil, err
+	}
+	if err = register(registerer, &m.limiterWait); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//register registers *collector, replacing it with the collector already
+//registered by another client if any
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector *T) error {
+	err := registerer.Register(*collector)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			*collector = existing
+			return nil
+		}
+	}
+	return err
+}
+
+func (m *metrics) middleware(next Handler) Handler {
+	return func(ctx context.Context, call *Call) (*http.Response, error) {
+		m.inFlight.Inc()
+		start := time.Now()
+		resp, err := next(ctx, call)
+		m.inFlight.Dec()
+		class := statusClass(resp)
+		m.requests.WithLabelValues(call.Service, call.Operation, class).Inc()
+		m.duration.WithLabelValues(call.Service, call.Operation, class).Observe(time.Since(start).Seconds())
+		if err != nil {
+			m.errors.WithLabelValues(call.Service, call.Operation, class).Inc()
+		}
+		return resp, err
+	}
+}
+
+//tokenRefreshed records the result of a session token refresh
+func (m *metrics) tokenRefreshed(err error) {
+	if err != nil {
+		m.tokenRefreshes.WithLabelValues("failure").Inc()
+		return
+	}
+	m.tokenRefreshes.WithLabelValues("success").Inc()
+	m.lastRefreshTime.SetToCurrentTime()
+}
+
+//statusClass returns the class of the response status, like 2xx, or error
+//when no response was received
+func statusClass(resp *http.Response) string {
+	if resp == nil {
+		return "error"
+	}
+	return strconv.Itoa(resp.StatusCode/100) + "xx"
+}
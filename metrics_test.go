@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/projects/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+	registry := prometheus.NewRegistry()
+	client, err := NewClient(server.URL, "", "", "token", WithMetrics(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// clients sharing a registerer share the collectors
+	other, err := NewClient(server.URL, "", "", "token", WithMetrics(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err = client.Projects.List(""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err = other.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.Delete("missing"); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	m := client.metrics
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("ProjectService", "List", "2xx")); got != 3 {
+		t.Fatalf("expected 3 successful list calls, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.errors.WithLabelValues("ProjectService", "Delete", "4xx")); got != 1 {
+		t.Fatalf("expected 1 failed delete call, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.errors.WithLabelValues("ProjectService", "List", "2xx")); got != 0 {
+		t.Fatalf("expected no failed list call, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.inFlight); got != 0 {
+		t.Fatalf("expected no call in flight, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.duration); got != 2 {
+		t.Fatalf("expected 2 latency series, got %d", got)
+	}
+}
+
+func TestMetricsTokenRefresh(t *testing.T) {
+	var logins, rejected int32
+	server := newAuthTestServer(t, &logins, &rejected)
+	registry := prometheus.NewRegistry()
+	client, err := NewClient(server.URL, "admin", "password", "revoked", WithMetrics(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	m := client.metrics
+	if got := testutil.ToFloat64(m.tokenRefreshes.WithLabelValues("success")); got != 1 {
+		t.Fatalf("expected 1 token refresh, got %v", got)
+	}
+	if testutil.ToFloat64(m.lastRefreshTime) == 0 {
+		t.Fatal("expected the last refresh time to be set")
+	}
+}
+
+func TestMetricsLimiterWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	registry := prometheus.NewRegistry()
+	client, err := NewClient(server.URL, "", "", "token", WithMetrics(registry), WithMaxInFlight(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := client.Projects.Get("default"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "argocd_client_limiter_wait_seconds" {
+			continue
+		}
+		histogram := family.GetMetric()[0].GetHistogram()
+		if histogram.GetSampleCount() != 4 || histogram.GetSampleSum() <= 0 {
+			t.Fatalf("expected 4 waits to be recorded, got %d totaling %vs", histogram.GetSampleCount(), histogram.GetSampleSum())
+		}
+		return
+	}
+	t.Fatal("limiter wait histogram not registered")
+}
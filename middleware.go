@@ -0,0 +1,91 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// Call describes a single service method call as seen by middlewares. A
+// middleware may change the request fields before calling the next handler,
+// for instance to add headers.
+type Call struct {
+	// Service and Operation name the method, e.g. ApplicationService and List
+	Service   string
+	Operation string
+	Method    string
+	// Path is relative to the server base URL, e.g. api/v1/applications
+	Path  string
+	Query url.Values
+	// Body is the value sent as JSON, nil for calls without a body and for
+	// sensitive calls
+	Body interface{}
+	// Sensitive calls send credentials, such as the login of
+	// SessionsService.CreateUserJWT and AccountsService.UpdatePassword. Their
+	// body is withheld and cannot be changed.
+	Sensitive bool
+	Header    http.Header
+	// Attributes describe the Argo CD resources the call is about, keyed by
+	// the Attribute constants
+	Attributes map[string]string
+	// Attempts is the number of round trips made, set once the call returned
+	Attempts int
+}
+
+// Attribute keys of Call.Attributes.
+const (
+	AttributeApplication = "argocd.application"
+	AttributeProject     = "argocd.project"
+	AttributeCluster     = "argocd.cluster"
+)
+
+// Handler performs a call, returning the response and the error the service
+// method will return, such as an *APIError or a *DecodeError.
+type Handler func(ctx context.Context, call *Call) (*http.Response, error)
+
+// Middleware wraps the handler performing every call of a Client.
+type Middleware func(next Handler) Handler
+
+//WithMiddleware wraps every call made by the client with middlewares. The
+//first middleware is the outermost one: it sees the call first and its
+//result last.
+func WithMiddleware(middlewares ...Middleware) ClientOptionFunc {
+	return func(c *Client) error {
+		for _, m := range middlewares {
+			if m == nil {
+				return errors.New("middleware is nil")
+			}
+		}
+		c.middlewares = append(c.middlewares, middlewares...)
+		return nil
+	}
+}
+
+//chain wraps handler with the middlewares of the client, inside the
+//instrumentation ones so that spans and metrics cover them
+func (c *Client) chain(handler Handler) Handler {
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		handler = c.middlewares[i](handler)
+	}
+	for i := len(c.instrumentation) - 1; i >= 0; i-- {
+		handler = c.instrumentation[i](handler)
+	}
+	return handler
+}
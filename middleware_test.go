@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Tenant") != "team-a" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	var (
+		order []string
+		seen  Call
+		err   error
+		resp  *http.Response
+	)
+	tenant := func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (*http.Response, error) {
+			order = append(order, "tenant")
+			call.Header.Set("X-Tenant", "team-a")
+			return next(ctx, call)
+		}
+	}
+	audit := func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (*http.Response, error) {
+			order = append(order, "audit")
+			resp, err = next(ctx, call)
+			seen = *call
+			return resp, err
+		}
+	}
+	client, err := NewClient(server.URL, "", "", "token", WithMiddleware(tenant, audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, callErr := client.Applications.GetManifests("guestbook", "HEAD")
+	if !reflect.DeepEqual(order, []string{"tenant", "audit"}) {
+		t.Fatalf("unexpected middleware order %v", order)
+	}
+	if seen.Service != "ApplicationService" || seen.Operation != "GetManifests" || seen.Method != http.MethodGet ||
+		seen.Path != "api/v1/applications/guestbook/manifests" || seen.Query.Get("revision") != "HEAD" || seen.Attempts != 1 {
+		t.Fatalf("unexpected call %+v", seen)
+	}
+	if resp.StatusCode != http.StatusNotFound || !IsNotFound(err) || err != callErr {
+		t.Fatalf("middleware should observe the status and the returned error, got %v", err)
+	}
+}
+
+func TestMiddlewareSensitiveCall(t *testing.T) {
+	const password = "s3cret-password"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/session" {
+			var login map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&login); err != nil || login["password"] != password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"fresh"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+	var calls []Call
+	audit := func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (*http.Response, error) {
+			// a middleware clearing the body must not break the login
+			call.Body = nil
+			resp, err := next(ctx, call)
+			calls = append(calls, *call)
+			return resp, err
+		}
+	}
+	client, err := NewClient(server.URL, "admin", password, "", WithMiddleware(audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[0].Operation != "CreateUserJWT" || !calls[0].Sensitive || calls[1].Sensitive {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+	for _, call := range calls {
+		if dump := fmt.Sprintf("%+v", call); strings.Contains(dump, password) {
+			t.Fatalf("the password reached a middleware: %s", dump)
+		}
+	}
+}
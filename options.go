@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"errors"
+	"net/http"
+)
+
+//WithStrictDecoding makes every service method fail with a *DecodeError when
+//a response carries fields unknown to this client, to surface Argo CD version
+//drift early
+func WithStrictDecoding() ClientOptionFunc {
+	return func(c *Client) error {
+		c.strictDecoding = true
+		return nil
+	}
+}
+
+//WithHTTPClient makes the client send requests through httpClient. The
+//http.Client is copied, so later changes to it are not observed.
+func WithHTTPClient(httpClient *http.Client) ClientOptionFunc {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("http client is nil")
+		}
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+//WithTransport makes the client send requests through transport, taking
+//precedence over the transport of a client given to WithHTTPClient
+func WithTransport(transport http.RoundTripper) ClientOptionFunc {
+	return func(c *Client) error {
+		if transport == nil {
+			return errors.New("transport is nil")
+		}
+		c.transport = transport
+		return nil
+	}
+}
+
+//WithHeader sends the header with every request, like the --header flag of
+//the argocd CLI. Middlewares see it in Call.Header.
+func WithHeader(key, value string) ClientOptionFunc {
+	return func(c *Client) error {
+		if len(key) == 0 {
+			return errors.New("header name is empty")
+		}
+		if c.headers == nil {
+			c.headers = http.Header{}
+		}
+		c.headers.Add(key, value)
+		return nil
+	}
+}
@@ -17,10 +17,10 @@ limitations under the License.
 package v1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/project"
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
-	"github.com/parnurzeal/gorequest"
 	"net/http"
 )
 
@@ -29,77 +29,92 @@ type ProjectService struct {
 }
 
 //List returns list of projects
-func (s *ProjectService) List(name string) (result v1alpha1.AppProjectList, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"projects").
+func (s *ProjectService) List(name string) (result v1alpha1.AppProjectList, resp *http.Response, err error) {
+	return s.ListWithContext(context.Background(), name)
+}
+
+//ListWithContext is List with a context controlling cancellation and deadlines
+func (s *ProjectService) ListWithContext(ctx context.Context, name string) (result v1alpha1.AppProjectList, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "ProjectService.List", http.MethodGet, apiV1Prefix+"projects").
+		Attr(AttributeProject, name).
 		Query(fmt.Sprintf("name=%s", name)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //Create creates an project
-func (s *ProjectService) Create(project v1alpha1.AppProject, upsert bool) (result v1alpha1.AppProject, resp gorequest.Response, err error) {
+func (s *ProjectService) Create(project v1alpha1.AppProject, upsert bool) (result v1alpha1.AppProject, resp *http.Response, err error) {
+	return s.CreateWithContext(context.Background(), project, upsert)
+}
+
+//CreateWithContext is Create with a context controlling cancellation and deadlines
+func (s *ProjectService) CreateWithContext(ctx context.Context, project v1alpha1.AppProject, upsert bool) (result v1alpha1.AppProject, resp *http.Response, err error) {
 	sendMap := make(map[string]interface{})
 	sendMap["upsert"] = upsert
 	sendMap["project"] = project
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+"projects").
+	resp, err = s.client.
+		newRequest(ctx, "ProjectService.Create", http.MethodPost, apiV1Prefix+"projects").
+		Attr(AttributeProject, project.Name).
 		SendStruct(&sendMap).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Idempotent(upsert).
+		Do(&result)
 	return
 }
 
 //Get returns a project by server address
-func (s *ProjectService) Get(name string) (result v1alpha1.AppProject, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"project/"+name).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+func (s *ProjectService) Get(name string) (result v1alpha1.AppProject, resp *http.Response, err error) {
+	return s.GetWithContext(context.Background(), name)
+}
+
+//GetWithContext is Get with a context controlling cancellation and deadlines
+func (s *ProjectService) GetWithContext(ctx context.Context, name string) (result v1alpha1.AppProject, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "ProjectService.Get", http.MethodGet, apiV1Prefix+"project/"+name).
+		Attr(AttributeProject, name).
+		Do(&result)
 	return
 }
 
 //Delete deletes a project
-func (s *ProjectService) Delete(name string) (success bool, resp gorequest.Response, err error) {
-
-	var (
-		errs []error
-	)
-	resp, _, errs = s.client.
-		newRequest(gorequest.DELETE, apiV1Prefix+"projects/"+name).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		success = true
-	}
-	err = s.client.ErrsWrapper(errs)
+func (s *ProjectService) Delete(name string) (success bool, resp *http.Response, err error) {
+	return s.DeleteWithContext(context.Background(), name)
+}
+
+//DeleteWithContext is Delete with a context controlling cancellation and deadlines
+func (s *ProjectService) DeleteWithContext(ctx context.Context, name string) (success bool, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "ProjectService.Delete", http.MethodDelete, apiV1Prefix+"projects/"+name).
+		Attr(AttributeProject, name).
+		Do(nil)
+	success = err == nil
+	return
+}
+
+//CreateToken creates a token for a project role
+func (s *ProjectService) CreateToken(request project.ProjectTokenCreateRequest) (result project.ProjectTokenResponse, resp *http.Response, err error) {
+	return s.CreateTokenWithContext(context.Background(), request)
+}
+
+//CreateTokenWithContext is CreateToken with a context controlling cancellation and deadlines
+func (s *ProjectService) CreateTokenWithContext(ctx context.Context, request project.ProjectTokenCreateRequest) (result project.ProjectTokenResponse, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "ProjectService.CreateToken", http.MethodPost, apiV1Prefix+"projects/"+request.Project+"/roles/"+request.Role+"/token").
+		Attr(AttributeProject, request.Project).
+		SendStruct(&request).
+		Do(&result)
 	return
 }
 
 //GetDetailedProject returns a project that include project, global project and scoped resources by name
-func (s *ProjectService) GetDetailedProject(name string) (success bool, resp gorequest.Response, err error) {
+func (s *ProjectService) GetDetailedProject(name string) (success bool, resp *http.Response, err error) {
+	return s.GetDetailedProjectWithContext(context.Background(), name)
+}
+
+//GetDetailedProjectWithContext is GetDetailedProject with a context controlling cancellation and deadlines
+func (s *ProjectService) GetDetailedProjectWithContext(ctx context.Context, name string) (success bool, resp *http.Response, err error) {
 	return
 }
@@ -17,10 +17,9 @@ limitations under the License.
 package v1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
-	"github.com/parnurzeal/gorequest"
 	"net/http"
 )
 
@@ -29,18 +28,15 @@ type RepoCredsService struct {
 }
 
 //ListRepositoryCredentials gets a list of all configured repository credential sets
-func (s *RepoCredsService) ListRepositoryCredentials(url string) (result v1alpha1.RepoCredsList, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"projects").
+func (s *RepoCredsService) ListRepositoryCredentials(url string) (result v1alpha1.RepoCredsList, resp *http.Response, err error) {
+	return s.ListRepositoryCredentialsWithContext(context.Background(), url)
+}
+
+//ListRepositoryCredentialsWithContext is ListRepositoryCredentials with a context controlling cancellation and deadlines
+func (s *RepoCredsService) ListRepositoryCredentialsWithContext(ctx context.Context, url string) (result v1alpha1.RepoCredsList, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "RepoCredsService.ListRepositoryCredentials", http.MethodGet, apiV1Prefix+"projects").
 		Query(fmt.Sprintf("url=%s", url)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
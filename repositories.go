@@ -17,12 +17,11 @@ limitations under the License.
 package v1
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	repositorypkg "github.com/argoproj/argo-cd/v2/pkg/apiclient/repository"
 	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
 	"github.com/argoproj/argo-cd/v2/reposerver/apiclient"
-	"github.com/parnurzeal/gorequest"
 	"net/http"
 )
 
@@ -31,160 +30,136 @@ type RepositoriesService struct {
 }
 
 //ListRepositories gets a list of all configured repositories
-func (s *RepositoriesService) ListRepositories(request repositorypkg.RepoQuery) (repoList v1alpha1.RepositoryList, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"repositories").
+func (s *RepositoriesService) ListRepositories(request repositorypkg.RepoQuery) (repoList v1alpha1.RepositoryList, resp *http.Response, err error) {
+	return s.ListRepositoriesWithContext(context.Background(), request)
+}
+
+//ListRepositoriesWithContext is ListRepositories with a context controlling cancellation and deadlines
+func (s *RepositoriesService) ListRepositoriesWithContext(ctx context.Context, request repositorypkg.RepoQuery) (repoList v1alpha1.RepositoryList, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.ListRepositories", http.MethodGet, apiV1Prefix+"repositories").
 		Query(&request).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &repoList)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&repoList)
 	return
 }
 
 //CreateRepository creates a new repository configuration
-func (s *RepositoriesService) CreateRepository(request repositorypkg.RepoCreateRequest) (result v1alpha1.Repository, resp gorequest.Response, err error) {
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+"repositories").
+func (s *RepositoriesService) CreateRepository(request repositorypkg.RepoCreateRequest) (result v1alpha1.Repository, resp *http.Response, err error) {
+	return s.CreateRepositoryWithContext(context.Background(), request)
+}
+
+//CreateRepositoryWithContext is CreateRepository with a context controlling cancellation and deadlines
+func (s *RepositoriesService) CreateRepositoryWithContext(ctx context.Context, request repositorypkg.RepoCreateRequest) (result v1alpha1.Repository, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.CreateRepository", http.MethodPost, apiV1Prefix+"repositories").
 		SendStruct(request.Repo).
 		Query(fmt.Sprintf("upsert=%t&credsOnly=%t", request.Upsert, request.CredsOnly)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Idempotent(request.Upsert).
+		Do(&result)
 	return
 }
 
 //UpdateRepository updates a repository configuration
-func (s *RepositoriesService) UpdateRepository(request repositorypkg.RepoUpdateRequest) (result v1alpha1.Repository, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.PUT, apiV1Prefix+"repositories/"+request.Repo.Repo).
+func (s *RepositoriesService) UpdateRepository(request repositorypkg.RepoUpdateRequest) (result v1alpha1.Repository, resp *http.Response, err error) {
+	return s.UpdateRepositoryWithContext(context.Background(), request)
+}
+
+//UpdateRepositoryWithContext is UpdateRepository with a context controlling cancellation and deadlines
+func (s *RepositoriesService) UpdateRepositoryWithContext(ctx context.Context, request repositorypkg.RepoUpdateRequest) (result v1alpha1.Repository, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.UpdateRepository", http.MethodPut, apiV1Prefix+"repositories/"+request.Repo.Repo).
 		SendStruct(request.Repo).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //GetRepository returns a repository or its credentials
-func (s *RepositoriesService) GetRepository(request repositorypkg.RepoQuery) (result v1alpha1.Repository, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"repositories/"+request.Repo).
+func (s *RepositoriesService) GetRepository(request repositorypkg.RepoQuery) (result v1alpha1.Repository, resp *http.Response, err error) {
+	return s.GetRepositoryWithContext(context.Background(), request)
+}
+
+//GetRepositoryWithContext is GetRepository with a context controlling cancellation and deadlines
+func (s *RepositoriesService) GetRepositoryWithContext(ctx context.Context, request repositorypkg.RepoQuery) (result v1alpha1.Repository, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.GetRepository", http.MethodGet, apiV1Prefix+"repositories/"+request.Repo).
 		Query(fmt.Sprintf("forceRefresh=%t", request.ForceRefresh)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //DeleteRepository deletes a repository from the configuration
-func (s *RepositoriesService) DeleteRepository(request repositorypkg.RepoQuery) (result v1alpha1.Repository, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.DELETE, apiV1Prefix+"repositories/"+request.Repo).
+func (s *RepositoriesService) DeleteRepository(request repositorypkg.RepoQuery) (result v1alpha1.Repository, resp *http.Response, err error) {
+	return s.DeleteRepositoryWithContext(context.Background(), request)
+}
+
+//DeleteRepositoryWithContext is DeleteRepository with a context controlling cancellation and deadlines
+func (s *RepositoriesService) DeleteRepositoryWithContext(ctx context.Context, request repositorypkg.RepoQuery) (result v1alpha1.Repository, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.DeleteRepository", http.MethodDelete, apiV1Prefix+"repositories/"+request.Repo).
 		Query(fmt.Sprintf("forceRefresh=%t", request.ForceRefresh)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //ListApps returns list of apps in the repo
-func (s *RepositoriesService) ListApps(query repositorypkg.RepoAppsQuery) (result repositorypkg.RepoAppsResponse, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"repositories/"+query.Repo+"/apps").
+func (s *RepositoriesService) ListApps(query repositorypkg.RepoAppsQuery) (result repositorypkg.RepoAppsResponse, resp *http.Response, err error) {
+	return s.ListAppsWithContext(context.Background(), query)
+}
+
+//ListAppsWithContext is ListApps with a context controlling cancellation and deadlines
+func (s *RepositoriesService) ListAppsWithContext(ctx context.Context, query repositorypkg.RepoAppsQuery) (result repositorypkg.RepoAppsResponse, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.ListApps", http.MethodGet, apiV1Prefix+"repositories/"+query.Repo+"/apps").
 		Query(&query).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //GetHelmCharts returns list of helm charts in the specified repository
-func (s *RepositoriesService) GetHelmCharts(request repositorypkg.RepoQuery) (result apiclient.HelmChartsResponse, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"repositories/"+request.Repo+"/helmcharts").
+func (s *RepositoriesService) GetHelmCharts(request repositorypkg.RepoQuery) (result apiclient.HelmChartsResponse, resp *http.Response, err error) {
+	return s.GetHelmChartsWithContext(context.Background(), request)
+}
+
+//GetHelmChartsWithContext is GetHelmCharts with a context controlling cancellation and deadlines
+func (s *RepositoriesService) GetHelmChartsWithContext(ctx context.Context, request repositorypkg.RepoQuery) (result apiclient.HelmChartsResponse, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.GetHelmCharts", http.MethodGet, apiV1Prefix+"repositories/"+request.Repo+"/helmcharts").
 		Query(fmt.Sprintf("forceRefresh=%t", request.ForceRefresh)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
-func (s *RepositoriesService) ListRefs(request repositorypkg.RepoQuery) (result apiclient.Refs, resp gorequest.Response, err error) {
 
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.GET, apiV1Prefix+"repositories/"+request.Repo+"/refs").
+//ListRefs returns the branches and tags of the repository
+func (s *RepositoriesService) ListRefs(request repositorypkg.RepoQuery) (result apiclient.Refs, resp *http.Response, err error) {
+	return s.ListRefsWithContext(context.Background(), request)
+}
+
+//ListRefsWithContext is ListRefs with a context controlling cancellation and deadlines
+func (s *RepositoriesService) ListRefsWithContext(ctx context.Context, request repositorypkg.RepoQuery) (result apiclient.Refs, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.ListRefs", http.MethodGet, apiV1Prefix+"repositories/"+request.Repo+"/refs").
 		Query(fmt.Sprintf("forceRefresh=%t", request.ForceRefresh)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
 
 //ValidateAccess validates access to a repository with given parameters
-func (s *RepositoriesService) ValidateAccess(request repositorypkg.RepoQuery) (result apiclient.Refs, resp gorequest.Response, err error) {
-
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+"repositories/"+request.Repo+"/validate").
+func (s *RepositoriesService) ValidateAccess(request repositorypkg.RepoQuery) (result apiclient.Refs, resp *http.Response, err error) {
+	return s.ValidateAccessWithContext(context.Background(), request)
+}
+
+//ValidateAccessWithContext is ValidateAccess with a context controlling cancellation and deadlines
+func (s *RepositoriesService) ValidateAccessWithContext(ctx context.Context, request repositorypkg.RepoQuery) (result apiclient.Refs, resp *http.Response, err error) {
+
+	resp, err = s.client.
+		newRequest(ctx, "RepositoriesService.ValidateAccess", http.MethodPost, apiV1Prefix+"repositories/"+request.Repo+"/validate").
 		Query(fmt.Sprintf("forceRefresh=%t", request.ForceRefresh)).
-		End()
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &result)
-	}
-	err = s.client.ErrsWrapper(errs)
+		Do(&result)
 	return
 }
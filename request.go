@@ -0,0 +1,374 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// request is a single API call bound to the context it was created with.
+// All of its state is private to the call, so requests built from the same
+// Client may be sent concurrently.
+type request struct {
+	ctx    context.Context
+	client *Client
+	// service and operation name the service method making the request
+	service   string
+	operation string
+	method    string
+	path      string
+	query     url.Values
+	body      interface{}
+	header    http.Header
+	// attributes describe the resources the request is about
+	attributes map[string]string
+	// anonymous requests carry no session token and are never re-authenticated
+	anonymous bool
+	// sensitive requests hide their body from middlewares
+	sensitive bool
+	// token is the session token the request was last sent with
+	token string
+	// idempotent requests are retried by default
+	idempotent bool
+	// stream leaves the body of successful responses open, see Stream
+	stream bool
+	// attempts counts how many times the request was sent
+	attempts int
+	// err is the first error hit while building the request
+	err error
+}
+
+//Query adds query parameters to the request. content is either an encoded
+//query string or a value whose JSON encoding is flattened into parameters,
+//the way the Argo CD gRPC gateway reads them.
+func (r *request) Query(content interface{}) *request {
+	if s, ok := content.(string); ok {
+		values, err := url.ParseQuery(s)
+		if err != nil {
+			r.setErr(err)
+			return r
+		}
+		for k, vs := range values {
+			for _, v := range vs {
+				r.query.Add(k, v)
+			}
+		}
+		return r
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		r.setErr(err)
+		return r
+	}
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err = dec.Decode(&fields); err != nil {
+		r.setErr(err)
+		return r
+	}
+	addQueryValues(r.query, "", fields)
+	return r
+}
+
+//addQueryValues flattens nested objects into dotted keys and repeats the key
+//for every element of a list
+func addQueryValues(query url.Values, prefix string, fields map[string]interface{}) {
+	for k, v := range fields {
+		if len(prefix) > 0 {
+			k = prefix + "." + k
+		}
+		switch t := v.(type) {
+		case map[string]interface{}:
+			addQueryValues(query, k, t)
+		case []interface{}:
+			for _, e := range t {
+				query.Add(k, queryValue(e))
+			}
+		case nil:
+		default:
+			query.Add(k, queryValue(t))
+		}
+	}
+}
+
+func queryValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+//SendStruct sets the request body to the JSON encoding of content
+func (r *request) SendStruct(content interface{}) *request {
+	r.body = content
+	return r
+}
+
+//SendMap sets the request body to the JSON encoding of content
+func (r *request) SendMap(content interface{}) *request {
+	r.body = content
+	return r
+}
+
+//Idempotent overrides whether the request is safe to retry, which by default
+//depends on its method. A create with upsert is idempotent despite being a POST.
+func (r *request) Idempotent(idempotent bool) *request {
+	r.idempotent = idempotent
+	return r
+}
+
+//Attr records that the request is about the resource named value, under one
+//of the Attribute keys
+func (r *request) Attr(key, value string) *request {
+	if len(value) == 0 {
+		return r
+	}
+	if r.attributes == nil {
+		r.attributes = map[string]string{}
+	}
+	r.attributes[key] = value
+	return r
+}
+
+//Anonymous sends the request without a session token
+func (r *request) Anonymous() *request {
+	r.anonymous = true
+	return r
+}
+
+//Sensitive hides the body of the request, which holds credentials, from the
+//middlewares of the client
+func (r *request) Sensitive() *request {
+	r.sensitive = true
+	return r
+}
+
+func (r *request) setErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+//Do sends the request through the middlewares of the client and decodes a
+//successful response body into v, which may be nil when the body is not
+//needed. Non-2xx responses are returned as *APIError.
+func (r *request) Do(v interface{}) (resp *http.Response, err error) {
+	header := http.Header{}
+	for k, vs := range r.client.headers {
+		header[k] = append([]string(nil), vs...)
+	}
+	call := &Call{
+		Service:    r.service,
+		Operation:  r.operation,
+		Method:     r.method,
+		Path:       r.path,
+		Query:      r.query,
+		Body:       r.body,
+		Header:     header,
+		Attributes: r.attributes,
+		Sensitive:  r.sensitive,
+	}
+	if r.sensitive {
+		call.Body = nil
+	}
+	return r.client.chain(func(ctx context.Context, call *Call) (*http.Response, error) {
+		// middlewares may have changed the call
+		r.ctx, r.method, r.path, r.query, r.header = ctx, call.Method, call.Path, call.Query, call.Header
+		if !r.sensitive {
+			r.body = call.Body
+		}
+		resp, err := r.do(v)
+		call.Attempts = r.attempts
+		return resp, err
+	})(r.ctx, call)
+}
+
+//Stream sends the request like Do but leaves the body of a successful
+//response unread, for the caller to consume a stream and close. Retries and
+//re-authentication apply until the response headers are received.
+func (r *request) Stream() (resp *http.Response, err error) {
+	r.stream = true
+	return r.Do(nil)
+}
+
+//do sends the request and decodes the response into v
+func (r *request) do(v interface{}) (resp *http.Response, err error) {
+	resp, body, err := r.EndBytes()
+	if err != nil {
+		return
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp, newAPIError(resp.StatusCode, body)
+	}
+	if v != nil && len(body) > 0 {
+		err = r.client.decode(resp.StatusCode, body, v)
+	}
+	return
+}
+
+//decode unmarshals body into v, returning a *DecodeError on failure
+func (c *Client) decode(statusCode int, body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if c.strictDecoding {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return newDecodeError(statusCode, body, err)
+	}
+	return nil
+}
+
+//build turns the request into an *http.Request bound to its context
+func (r *request) build() (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	u := r.client.baseURL.String() + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	var body io.Reader
+	if r.body != nil {
+		data, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(r.ctx, r.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.client.UserAgent != "" {
+		req.Header.Set("User-Agent", r.client.UserAgent)
+	}
+	// headers added by middlewares override the defaults above
+	for k, vs := range r.header {
+		req.Header[k] = vs
+	}
+	if r.anonymous {
+		return req, nil
+	}
+	r.token = r.client.getToken()
+	if token := r.token; len(token) > 0 {
+		if !strings.HasPrefix(token, "Bearer ") {
+			token = "Bearer " + token
+		}
+		req.Header.Set("Authorization", token)
+	}
+	return req, nil
+}
+
+//EndBytes sends the request, retrying transient failures according to the
+//retry policy, and returns the response body
+func (r *request) EndBytes() (resp *http.Response, body []byte, err error) {
+	defer func() {
+		err = unwrapTransport(err)
+	}()
+	policy := r.client.policyFor(r)
+	for {
+		r.attempts++
+		resp, body, err = r.authenticated()
+		if policy == nil || r.attempts >= policy.MaxAttempts || !policy.shouldRetry(r, resp, err) {
+			return
+		}
+		event := RetryEvent{Method: r.method, Path: r.path, Attempt: r.attempts, Err: unwrapTransport(err)}
+		if resp != nil {
+			event.StatusCode = resp.StatusCode
+		}
+		event.Delay = policy.backoff(r.attempts, resp)
+		if policy.OnRetry != nil {
+			policy.OnRetry(event)
+		}
+		if sleepErr := sleep(r.ctx, event.Delay); sleepErr != nil {
+			// the caller gave up: report why rather than the retried failure
+			err = sleepErr
+			return
+		}
+	}
+}
+
+//authenticated sends the request once. When the client holds credentials, an
+//expiring session is renewed before sending and a request rejected with 401
+//is replayed once after logging in again.
+func (r *request) authenticated() (resp *http.Response, body []byte, err error) {
+	if r.anonymous || !r.client.canLogin() {
+		return r.send()
+	}
+	if err = r.client.ensureFreshToken(r.ctx); err != nil {
+		return nil, nil, err
+	}
+	resp, body, err = r.send()
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return
+	}
+	if err = r.client.refreshToken(r.ctx, r.token); err != nil {
+		return nil, nil, err
+	}
+	return r.send()
+}
+
+//send performs a single round trip of the request
+func (r *request) send() (resp *http.Response, body []byte, err error) {
+	req, err := r.build()
+	if err != nil {
+		return nil, nil, err
+	}
+	if l := r.client.limiter; l != nil {
+		release, err := l.acquire(r.ctx, r.client.metrics)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer release()
+	}
+	resp, err = r.client.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, &transportError{err: err}
+	}
+	if r.stream && resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		// streams do not hold an in-flight slot once established
+		return resp, nil, nil
+	}
+	defer resp.Body.Close()
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, &transportError{err: err}
+	}
+	// keep the body readable for callers inspecting the response
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, body, nil
+}
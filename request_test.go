@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRequestQueryEncoding(t *testing.T) {
+	name, resourceVersion := "guestbook", "42"
+	var query map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = client.Applications.List(application.ApplicationQuery{
+		Name:            &name,
+		ResourceVersion: &resourceVersion,
+		Projects:        []string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query["name"][0] != name || query["resourceVersion"][0] != resourceVersion || len(query["projects"]) != 2 {
+		t.Fatalf("unexpected query %v", query)
+	}
+}
+
+// TestConcurrentRequests is meant to be run with -race: requests sharing one
+// Client must not share any mutable state.
+func TestConcurrentRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// echo the body back with the query parameter, so a response can be
+		// matched to the request it answers
+		var c v1alpha1.Cluster
+		_ = json.NewDecoder(r.Body).Decode(&c)
+		c.Server = r.URL.Query().Get("upsert")
+		_ = json.NewEncoder(w).Encode(c)
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := strings.Repeat("c", i+1)
+			upsert := i%2 == 0
+			got, _, err := client.Clusters.CreateWithContext(context.Background(), v1alpha1.Cluster{Name: name}, upsert)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got.Name != name || got.Server != strconv.FormatBool(upsert) {
+				t.Errorf("response for %s leaked into another request: %+v", name, got)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestWithTransport(t *testing.T) {
+	var calls int32
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"items":[]}`)),
+			Request:    req,
+		}, nil
+	})
+	client, err := NewClient("https://argocd.example.com", "", "", "token",
+		WithHTTPClient(&http.Client{}), WithTransport(transport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatalf("expected the custom transport to be used once, got %d", calls)
+	}
+}
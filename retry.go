@@ -0,0 +1,209 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how calls failing with a transient error are retried.
+// Only idempotent verbs are retried unless RetryNonIdempotent is set, either
+// on the client policy or for a single call through ContextWithRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts; values below 2 disable retries
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry, doubled for every
+	// further retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Jitter is the fraction, between 0 and 1, of each delay that is randomized
+	Jitter float64
+	// RetryableStatus lists the HTTP statuses worth retrying, by default 502,
+	// 503 and 504
+	RetryableStatus []int
+	// RetryNonIdempotent also retries POST and PATCH calls
+	RetryNonIdempotent bool
+	// OnRetry, when set, is called before sleeping ahead of every retry
+	OnRetry func(RetryEvent)
+}
+
+// RetryEvent describes a failed attempt about to be retried.
+type RetryEvent struct {
+	Method string
+	Path   string
+	// Attempt is the number of the attempt that failed, starting at 1
+	Attempt int
+	// StatusCode is the status of the failed attempt, 0 on transport errors
+	StatusCode int
+	Err        error
+	Delay      time.Duration
+}
+
+var defaultRetryableStatus = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+//DefaultRetryPolicy returns a policy suited to Argo CD servers behind an
+//ingress: up to 4 attempts with exponential backoff from 200ms to 5s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Jitter:         0.2,
+	}
+}
+
+//WithRetryPolicy retries calls failing with a transient error according to
+//policy. Without it calls are attempted once.
+func WithRetryPolicy(policy RetryPolicy) ClientOptionFunc {
+	return func(c *Client) error {
+		if policy.Jitter < 0 || policy.Jitter > 1 {
+			return errors.New("retry jitter must be between 0 and 1")
+		}
+		if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 {
+			return errors.New("retry backoff must not be negative")
+		}
+		c.retryPolicy = &policy
+		return nil
+	}
+}
+
+type retryPolicyKey struct{}
+
+//ContextWithRetryPolicy overrides the client retry policy for calls made with
+//the returned context, for instance to retry a POST known to be safe
+func ContextWithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, &policy)
+}
+
+//policyFor returns the policy applying to r, nil when it is not retried
+func (c *Client) policyFor(r *request) *RetryPolicy {
+	if p, ok := r.ctx.Value(retryPolicyKey{}).(*RetryPolicy); ok {
+		return p
+	}
+	return c.retryPolicy
+}
+
+//shouldRetry reports whether an attempt that ended with resp or err is worth
+//retrying. Among errors only failed round trips are: a request that cannot be
+//built or a rejected login fails the same way on every attempt.
+func (p *RetryPolicy) shouldRetry(r *request, resp *http.Response, err error) bool {
+	if !r.idempotent && !p.RetryNonIdempotent {
+		return false
+	}
+	if err != nil {
+		var transport *transportError
+		// the caller gave up, retrying cannot help
+		return errors.As(err, &transport) && r.ctx.Err() == nil
+	}
+	statuses := p.RetryableStatus
+	if statuses == nil {
+		statuses = defaultRetryableStatus
+	}
+	for _, s := range statuses {
+		if resp.StatusCode == s {
+			return true
+		}
+	}
+	return false
+}
+
+//backoff returns the delay before retrying the given failed attempt,
+//honoring a Retry-After header sent by the server
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return d
+		}
+	}
+	d := float64(p.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	d -= d * p.Jitter * rand.Float64()
+	return time.Duration(d)
+}
+
+//retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// transportError wraps the error of a round trip that reached no response,
+// telling it apart from errors raised before sending.
+type transportError struct {
+	err error
+}
+
+func (e *transportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+//unwrapTransport returns err without its transportError wrapper
+func unwrapTransport(err error) error {
+	if transport, ok := err.(*transportError); ok {
+		return transport.err
+	}
+	return err
+}
+
+//isIdempotent reports whether repeating a call with method has no additional
+//effect on the server
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+//sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
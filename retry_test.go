@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+// newFlakyServer fails the first failures calls with 503 and a Retry-After of
+// zero seconds.
+func newFlakyServer(t *testing.T, failures int32, calls *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testRetryPolicy(events *[]RetryEvent) RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.OnRetry = func(e RetryEvent) {
+		*events = append(*events, e)
+	}
+	return policy
+}
+
+func TestRetryIdempotentCall(t *testing.T) {
+	var calls int32
+	var events []RetryEvent
+	server := newFlakyServer(t, 2, &calls)
+	client, err := NewClient(server.URL, "", "", "token", WithRetryPolicy(testRetryPolicy(&events)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.Get("default"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || len(events) != 2 {
+		t.Fatalf("expected 3 calls and 2 retries, got %d and %d", calls, len(events))
+	}
+	if events[1].Attempt != 2 || events[1].StatusCode != http.StatusServiceUnavailable || events[1].Delay != 0 {
+		t.Fatalf("unexpected retry event %+v", events[1])
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int32
+	var events []RetryEvent
+	server := newFlakyServer(t, 10, &calls)
+	client, err := NewClient(server.URL, "", "", "token", WithRetryPolicy(testRetryPolicy(&events)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = client.Projects.Get("default")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last 503 to be returned, got %v", err)
+	}
+	if calls != 4 {
+		t.Fatalf("expected 4 attempts, got %d", calls)
+	}
+}
+
+func TestRetryCanceledDuringBackoff(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Minute
+	policy.OnRetry = func(RetryEvent) {
+		cancel()
+	}
+	client, err := NewClient(server.URL, "", "", "token", WithRetryPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, _, err = client.Projects.GetWithContext(ctx, "default")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation to be returned, got %v", err)
+	}
+	if calls != 1 || time.Since(start) > 10*time.Second {
+		t.Fatalf("expected the backoff to be interrupted after 1 call, got %d calls in %s", calls, time.Since(start))
+	}
+}
+
+func TestRetryDroppedConnection(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = conn.Close()
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	var events []RetryEvent
+	client, err := NewClient(server.URL, "", "", "token", WithRetryPolicy(testRetryPolicy(&events)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.Get("default"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || len(events) != 1 || events[0].StatusCode != 0 || events[0].Err == nil {
+		t.Fatalf("expected the dropped connection to be retried once, got %d calls and %+v", calls, events)
+	}
+	if _, ok := events[0].Err.(*transportError); ok {
+		t.Fatal("retry events must carry the transport error itself")
+	}
+}
+
+func TestRetryRejectedLogin(t *testing.T) {
+	var logins, calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/session" {
+			atomic.AddInt32(&logins, 1)
+		} else {
+			atomic.AddInt32(&calls, 1)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid username or password","code":16}`))
+	}))
+	defer server.Close()
+	var events []RetryEvent
+	client, err := NewClient(server.URL, "admin", "wrong", "", WithRetryPolicy(testRetryPolicy(&events)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = client.Projects.List("")
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the login rejection, got %v", err)
+	}
+	if logins != 1 || calls != 0 || len(events) != 0 {
+		t.Fatalf("a rejected login must not be retried, got %d logins, %d calls and %d retries", logins, calls, len(events))
+	}
+}
+
+func TestRetryInvalidRequest(t *testing.T) {
+	var calls int32
+	var events []RetryEvent
+	server := newFlakyServer(t, 0, &calls)
+	client, err := NewClient(server.URL, "", "", "token", WithRetryPolicy(testRetryPolicy(&events)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Applications.ListResourceActions(ApplicationResourceRequest{Name: "guestbook", Kind: "x;y"}); err == nil {
+		t.Fatal("expected the invalid query to fail")
+	}
+	if calls != 0 || len(events) != 0 {
+		t.Fatalf("an invalid request must not be retried, got %d calls and %d retries", calls, len(events))
+	}
+}
+
+func TestRetryNonIdempotentCall(t *testing.T) {
+	var calls int32
+	var events []RetryEvent
+	server := newFlakyServer(t, 1, &calls)
+	client, err := NewClient(server.URL, "", "", "token", WithRetryPolicy(testRetryPolicy(&events)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Clusters.Create(v1alpha1.Cluster{}, false); err == nil || calls != 1 {
+		t.Fatalf("a plain POST must not be retried, got %d calls", calls)
+	}
+	atomic.StoreInt32(&calls, 0)
+	if _, _, err = client.Clusters.Create(v1alpha1.Cluster{}, true); err != nil || calls != 2 {
+		t.Fatalf("a POST with upsert should be retried, got %d calls: %v", calls, err)
+	}
+	atomic.StoreInt32(&calls, 0)
+	policy := testRetryPolicy(&events)
+	policy.RetryNonIdempotent = true
+	ctx := ContextWithRetryPolicy(context.Background(), policy)
+	if _, _, err = client.Clusters.CreateWithContext(ctx, v1alpha1.Cluster{}, false); err != nil || calls != 2 {
+		t.Fatalf("the per-call policy should retry the POST, got %d calls: %v", calls, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("3"); !ok || d != 3*time.Second {
+		t.Fatalf("unexpected delay %v", d)
+	}
+	if d, ok := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); !ok || d <= 0 || d > time.Minute {
+		t.Fatalf("unexpected delay %v", d)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Fatal("invalid values must be ignored")
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+// ErrNoPreviousDeployment is returned by RollbackToPrevious for an
+// application deployed less than twice.
+var ErrNoPreviousDeployment = errors.New("argocd: no previous deployment to roll back to")
+
+// AutoSyncEnabledError is returned by RollbackToPrevious for an application
+// with automated sync, which Argo CD refuses to roll back.
+type AutoSyncEnabledError struct {
+	Application string
+}
+
+func (e *AutoSyncEnabledError) Error() string {
+	return fmt.Sprintf("argocd: rollback of %s refused: automated sync is enabled, disable it first", e.Application)
+}
+
+//IsAutoSyncEnabled reports whether err is a rollback refused for automated sync
+func IsAutoSyncEnabled(err error) bool {
+	var e *AutoSyncEnabledError
+	return errors.As(err, &e)
+}
+
+//Rollback syncs the application named in request back to the deployment of
+//its history with the id of request, returning the application with the
+//operation started
+func (s *ApplicationService) Rollback(request application.ApplicationRollbackRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	return s.RollbackWithContext(context.Background(), request)
+}
+
+//RollbackWithContext is Rollback with a context controlling cancellation and deadlines
+func (s *ApplicationService) RollbackWithContext(ctx context.Context, request application.ApplicationRollbackRequest) (result v1alpha1.Application, resp *http.Response, err error) {
+	namespace, name := splitApplicationName(*request.Name)
+	request.Name = &name
+	resp, err = s.client.
+		newRequest(ctx, "ApplicationService.Rollback", http.MethodPost, apiV1Prefix+"applications/"+name+"/rollback").
+		Attr(AttributeApplication, name).
+		SendStruct(&request).
+		Query(appNamespaceQuery(namespace)).
+		Do(&result)
+	return
+}
+
+//RollbackToPrevious rolls the application name back to the deployment
+//before its current one in its history. An application with automated sync
+//is refused with an *AutoSyncEnabledError before anything is sent.
+func (s *ApplicationService) RollbackToPrevious(name string, dryRun, prune bool) (result v1alpha1.Application, resp *http.Response, err error) {
+	return s.RollbackToPreviousWithContext(context.Background(), name, dryRun, prune)
+}
+
+//RollbackToPreviousWithContext is RollbackToPrevious with a context controlling cancellation and deadlines
+func (s *ApplicationService) RollbackToPreviousWithContext(ctx context.Context, name string, dryRun, prune bool) (result v1alpha1.Application, resp *http.Response, err error) {
+	app, resp, err := s.GetWithContext(ctx, application.ApplicationQuery{Name: &name})
+	if err != nil {
+		return
+	}
+	if app.Spec.SyncPolicy != nil && app.Spec.SyncPolicy.Automated != nil {
+		err = &AutoSyncEnabledError{Application: name}
+		return
+	}
+	history := app.Status.History
+	if len(history) < 2 {
+		err = fmt.Errorf("%w: %s", ErrNoPreviousDeployment, name)
+		return
+	}
+	id := history[len(history)-2].ID
+	return s.RollbackWithContext(ctx, application.ApplicationRollbackRequest{Name: &name, Id: &id, DryRun: &dryRun, Prune: &prune})
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+)
+
+func newRollbackTestServer(t *testing.T, app string, rollbacks *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/applications/guestbook":
+			if len(app) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":"applications.argoproj.io \"guestbook\" not found","code":5}`))
+				return
+			}
+			_, _ = w.Write([]byte(app))
+		case "/api/v1/applications/guestbook/rollback":
+			var request application.ApplicationRollbackRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.Method != http.MethodPost {
+				t.Errorf("unexpected rollback %s: %v", r.Method, err)
+			}
+			*rollbacks = append(*rollbacks, fmt.Sprintf("%s %d %t %t",
+				request.GetName(), request.GetId(), request.GetDryRun(), request.GetPrune()))
+			_, _ = w.Write([]byte(`{"metadata":{"name":"guestbook"},"operation":{"sync":{"revision":"v1"}}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}))
+}
+
+func TestRollback(t *testing.T) {
+	var rollbacks []string
+	server := newRollbackTestServer(t, "", &rollbacks)
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, id, prune := "guestbook", int64(3), true
+	app, _, err := client.Applications.Rollback(application.ApplicationRollbackRequest{Name: &name, Id: &id, Prune: &prune})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Operation == nil || fmt.Sprint(rollbacks) != "[guestbook 3 false true]" {
+		t.Fatalf("unexpected rollback %v: %+v", rollbacks, app)
+	}
+}
+
+func TestRollbackToPrevious(t *testing.T) {
+	history := `"status":{"history":[{"id":1,"revision":"v1"},{"id":2,"revision":"v2"},{"id":3,"revision":"v3"}]}`
+	for _, test := range []struct {
+		name, app, rollbacks string
+		check                func(error) bool
+	}{
+		{
+			name:      "previous",
+			app:       `{"metadata":{"name":"guestbook"},"spec":{"syncPolicy":{}},` + history + `}`,
+			rollbacks: "[guestbook 2 true false]",
+			check:     func(err error) bool { return err == nil },
+		},
+		{
+			name:  "auto sync",
+			app:   `{"metadata":{"name":"guestbook"},"spec":{"syncPolicy":{"automated":{"prune":true}}},` + history + `}`,
+			check: IsAutoSyncEnabled,
+		},
+		{
+			name:  "single deployment",
+			app:   `{"metadata":{"name":"guestbook"},"status":{"history":[{"id":1,"revision":"v1"}]}}`,
+			check: func(err error) bool { return errors.Is(err, ErrNoPreviousDeployment) },
+		},
+		{
+			name:  "missing",
+			check: IsNotFound,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var rollbacks []string
+			server := newRollbackTestServer(t, test.app, &rollbacks)
+			defer server.Close()
+			client, err := NewClient(server.URL, "", "", "token")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = client.Applications.RollbackToPrevious("guestbook", true, false)
+			if !test.check(err) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(test.rollbacks) > 0 && fmt.Sprint(rollbacks) != test.rollbacks || len(test.rollbacks) == 0 && len(rollbacks) > 0 {
+				t.Fatalf("unexpected rollbacks %v", rollbacks)
+			}
+		})
+	}
+}
+
+func TestRollbackToPreviousAppNamespace(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("appNamespace"))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"metadata":{"name":"guestbook","namespace":"team"},` +
+				`"status":{"history":[{"id":1,"revision":"v1"},{"id":2,"revision":"v2"}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"metadata":{"name":"guestbook","namespace":"team"}}`))
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Applications.RollbackToPrevious("team/guestbook", false, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "[GET /api/v1/applications/guestbook team POST /api/v1/applications/guestbook/rollback team]"
+	if fmt.Sprint(requests) != want {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+}
@@ -17,10 +17,8 @@ limitations under the License.
 package v1
 
 import (
-	"encoding/json"
+	"context"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/session"
-	"github.com/parnurzeal/gorequest"
-
 	"net/http"
 )
 
@@ -29,21 +27,20 @@ type SessionsService struct {
 }
 
 //CreateUserJWT Create a new JWT for authentication and set a cookie if using HTTP
-func (s *SessionsService) CreateUserJWT() (token session.SessionResponse, resp gorequest.Response, err error) {
+func (s *SessionsService) CreateUserJWT() (token session.SessionResponse, resp *http.Response, err error) {
+	return s.CreateUserJWTWithContext(context.Background())
+}
+
+//CreateUserJWTWithContext is CreateUserJWT with a context controlling cancellation and deadlines
+func (s *SessionsService) CreateUserJWTWithContext(ctx context.Context) (token session.SessionResponse, resp *http.Response, err error) {
 	au := make(map[string]string)
 	au["username"] = s.client.username
 	au["password"] = s.client.password
-	var (
-		data string
-		errs []error
-	)
-	resp, data, errs = s.client.
-		newRequest(gorequest.POST, apiV1Prefix+"session").
-		SendMap(au).End()
-
-	if resp.StatusCode == http.StatusOK {
-		_ = json.Unmarshal([]byte(data), &token)
-	}
-	err = s.client.ErrsWrapper(errs)
+	resp, err = s.client.
+		newRequest(ctx, "SessionsService.CreateUserJWT", http.MethodPost, apiV1Prefix+"session").
+		SendMap(au).
+		Sensitive().
+		Anonymous().
+		Do(&token)
 	return
 }
@@ -16,7 +16,14 @@ limitations under the License.
 
 package v1
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
 
 func TestGetUserSession(t *testing.T) {
 	client, err := NewClient(TestAddress, TestUsername, TestPwd, "")
@@ -29,3 +36,57 @@ func TestGetUserSession(t *testing.T) {
 	}
 	t.Logf("token: %s", token.Token)
 }
+
+// newBlockingServer accepts requests and holds them until the client gives up
+// or the test ends, signaling started once a request arrived.
+func newBlockingServer(t *testing.T, started chan<- struct{}) *httptest.Server {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server
+}
+
+func TestInitWithCanceledContext(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server := newBlockingServer(t, started)
+	client, err := NewClient(server.URL, "admin", "password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-started
+		cancel()
+	}()
+	if err = client.InitWithContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the login to be canceled, got %v", err)
+	}
+}
+
+func TestServiceCallDeadline(t *testing.T) {
+	server := newBlockingServer(t, nil)
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err = client.Projects.ListWithContext(ctx, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("the call outlived its deadline by %v", elapsed)
+	}
+}
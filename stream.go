@@ -0,0 +1,102 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// streamReconnect bounds the delay before a broken stream is reopened.
+var streamReconnect = RetryPolicy{
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Jitter:         0.2,
+}
+
+// streamMessage is a message of a stream served by the Argo CD gateway, one
+// JSON object per line carrying either a result or an error.
+type streamMessage struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		GrpcCode   codes.Code `json:"grpc_code"`
+		HTTPCode   int        `json:"http_code"`
+		Message    string     `json:"message"`
+		HTTPStatus string     `json:"http_status"`
+	} `json:"error"`
+}
+
+//readStreamMessage decodes the next message of a stream into result. An
+//error sent by the server is returned as an *APIError.
+func (c *Client) readStreamMessage(dec *json.Decoder, result interface{}) error {
+	var msg streamMessage
+	if err := dec.Decode(&msg); err != nil {
+		return err
+	}
+	if msg.Error != nil {
+		return &APIError{
+			StatusCode: msg.Error.HTTPCode,
+			Code:       msg.Error.GrpcCode,
+			Message:    msg.Error.Message,
+			Err:        msg.Error.Message,
+		}
+	}
+	return c.decode(http.StatusOK, msg.Result, result)
+}
+
+//reconnectable reports whether a stream that ended with err is worth
+//reopening: the server went away or failed, rather than refused the stream or
+//does not implement it
+func reconnectable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode == http.StatusNotImplemented || apiErr.Code == codes.Unimplemented {
+			return false
+		}
+		return apiErr.StatusCode == 0 || apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	return !IsDecodeError(err)
+}
+
+//reconnect runs open until it returns an error that is not reconnectable or
+//ctx is done, backing off between attempts. open reports whether it received
+//anything, which resets the backoff.
+func reconnect(ctx context.Context, open func() (received bool, err error)) error {
+	failures := 0
+	for {
+		received, err := open()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !reconnectable(err) {
+			return err
+		}
+		if received {
+			failures = 0
+		}
+		failures++
+		if err = sleep(ctx, streamReconnect.backoff(failures, nil)); err != nil {
+			return err
+		}
+	}
+}
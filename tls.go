@@ -0,0 +1,166 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// tlsOptions collects the TLS settings given through ClientOptionFuncs. They
+// are validated when the option is applied, so NewClient fails early on a bad
+// certificate rather than on the first request.
+type tlsOptions struct {
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+	serverName   string
+	minVersion   uint16
+	insecure     bool
+}
+
+func (c *Client) tlsOpts() *tlsOptions {
+	if c.tlsOptions == nil {
+		c.tlsOptions = &tlsOptions{}
+	}
+	return c.tlsOptions
+}
+
+//WithCACertFile trusts the PEM encoded certificates in file in addition to
+//the system roots
+func WithCACertFile(file string) ClientOptionFunc {
+	return func(c *Client) error {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("reading CA bundle: %w", err)
+		}
+		return WithCACertPEM(pem)(c)
+	}
+}
+
+//WithCACertPEM trusts the PEM encoded certificates in addition to the system
+//roots
+func WithCACertPEM(pem []byte) ClientOptionFunc {
+	return func(c *Client) error {
+		o := c.tlsOpts()
+		if o.rootCAs == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			o.rootCAs = pool
+		}
+		if !o.rootCAs.AppendCertsFromPEM(pem) {
+			return errors.New("CA bundle contains no PEM encoded certificates")
+		}
+		return nil
+	}
+}
+
+//WithClientCertificateFile presents the certificate and key in the given PEM
+//files to the server for mutual TLS
+func WithClientCertificateFile(certFile, keyFile string) ClientOptionFunc {
+	return func(c *Client) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %w", err)
+		}
+		c.tlsOpts().certificates = append(c.tlsOpts().certificates, cert)
+		return nil
+	}
+}
+
+//WithClientCertificatePEM presents the PEM encoded certificate and key to the
+//server for mutual TLS
+func WithClientCertificatePEM(certPEM, keyPEM []byte) ClientOptionFunc {
+	return func(c *Client) error {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %w", err)
+		}
+		c.tlsOpts().certificates = append(c.tlsOpts().certificates, cert)
+		return nil
+	}
+}
+
+//WithServerName overrides the name used for SNI and to verify the server
+//certificate, for servers reached through an address not in the certificate
+func WithServerName(name string) ClientOptionFunc {
+	return func(c *Client) error {
+		if len(name) == 0 {
+			return errors.New("server name is empty")
+		}
+		c.tlsOpts().serverName = name
+		return nil
+	}
+}
+
+//WithMinTLSVersion refuses to negotiate a TLS version older than version,
+//one of the tls.VersionTLS* constants
+func WithMinTLSVersion(version uint16) ClientOptionFunc {
+	return func(c *Client) error {
+		switch version {
+		case tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13:
+		default:
+			return fmt.Errorf("unknown TLS version %#x", version)
+		}
+		c.tlsOpts().minVersion = version
+		return nil
+	}
+}
+
+//WithInsecureSkipVerify disables verification of the server certificate. It
+//should only be used against test servers.
+func WithInsecureSkipVerify() ClientOptionFunc {
+	return func(c *Client) error {
+		c.tlsOpts().insecure = true
+		return nil
+	}
+}
+
+//config validates the combination of options and builds the tls.Config
+func (o *tlsOptions) config() (*tls.Config, error) {
+	if o.insecure && o.rootCAs != nil {
+		return nil, errors.New("a CA bundle cannot be combined with skipping verification")
+	}
+	return &tls.Config{
+		RootCAs:            o.rootCAs,
+		Certificates:       o.certificates,
+		ServerName:         o.serverName,
+		MinVersion:         o.minVersion,
+		InsecureSkipVerify: o.insecure,
+	}, nil
+}
+
+//applyTLS returns a copy of transport configured with the TLS options.
+//Custom RoundTrippers cannot be configured and are rejected.
+func (o *tlsOptions) applyTLS(transport http.RoundTripper) (http.RoundTripper, error) {
+	config, err := o.config()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("TLS options cannot be applied to transport %T", transport)
+	}
+	t = t.Clone()
+	t.TLSClientConfig = config
+	return t, nil
+}
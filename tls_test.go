@@ -0,0 +1,85 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTLSTestServer(t *testing.T) (*httptest.Server, []byte) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return server, caPEM
+}
+
+func TestTLSVerifiesByDefault(t *testing.T) {
+	server, _ := newTLSTestServer(t)
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err == nil {
+		t.Fatal("expected an unknown authority error")
+	}
+}
+
+func TestTLSWithCACertPEM(t *testing.T) {
+	server, caPEM := newTLSTestServer(t)
+	client, err := NewClient(server.URL, "", "", "token", WithCACertPEM(caPEM), WithMinTLSVersion(tls.VersionTLS12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSWithInsecureSkipVerify(t *testing.T) {
+	server, _ := newTLSTestServer(t)
+	client, err := NewClient(server.URL, "", "", "token", WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSOptionsValidation(t *testing.T) {
+	_, caPEM := newTLSTestServer(t)
+	cases := map[string][]ClientOptionFunc{
+		"invalid CA":           {WithCACertPEM([]byte("not a certificate"))},
+		"missing CA file":      {WithCACertFile("testdata/missing.pem")},
+		"invalid client cert":  {WithClientCertificatePEM([]byte("cert"), []byte("key"))},
+		"unknown TLS version":  {WithMinTLSVersion(0x0200)},
+		"empty server name":    {WithServerName("")},
+		"insecure with CA":     {WithCACertPEM(caPEM), WithInsecureSkipVerify()},
+		"custom round tripper": {WithTransport(roundTripperFunc(nil)), WithServerName("argocd")},
+	}
+	for name, options := range cases {
+		if _, err := NewClient("https://argocd.example.com", "", "", "token", options...); err == nil {
+			t.Errorf("%s: expected NewClient to fail", name)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/project"
+)
+
+// Token is a credential supplied by a TokenSource.
+type Token struct {
+	AccessToken string
+	// Expiry is when the token stops being valid. When zero, the exp claim of
+	// a JWT is used, and other tokens are replaced only once rejected.
+	Expiry time.Time
+}
+
+// TokenSource supplies the tokens a Client authenticates with. The client
+// caches the token and asks for a new one shortly before it expires or once
+// the server rejects it, with concurrent callers sharing a single request.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+//Token calls f
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+//WithTokenSource authenticates the client with the tokens of source instead
+//of logging in with a username and password
+func WithTokenSource(source TokenSource) ClientOptionFunc {
+	return func(c *Client) error {
+		if source == nil {
+			return errors.New("token source is nil")
+		}
+		c.tokenSource = source
+		return nil
+	}
+}
+
+//FileTokenSource reads the token from path, for instance a file a sidecar
+//rotates. The file is read again every time a new token is needed.
+func FileTokenSource(path string) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &Token{AccessToken: strings.TrimSpace(string(data))}, nil
+	})
+}
+
+// ExecConfig describes a credential plugin run by ExecTokenSource.
+type ExecConfig struct {
+	Command string
+	Args    []string
+	// Env is added to the environment of the client process, in the
+	// KEY=value form
+	Env []string
+}
+
+// execCredential is the output of a kubectl credential plugin.
+type execCredential struct {
+	Status *struct {
+		Token               string     `json:"token"`
+		ExpirationTimestamp *time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+//ExecTokenSource runs a credential plugin to obtain tokens. The plugin prints
+//either an ExecCredential like kubectl plugins, whose status carries the
+//token and its expirationTimestamp, or the bare token.
+func ExecTokenSource(config ExecConfig) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		cmd := exec.CommandContext(ctx, config.Command, config.Args...)
+		cmd.Env = append(os.Environ(), config.Env...)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("running %s: %w: %s", config.Command, err, strings.TrimSpace(stderr.String()))
+		}
+		output := bytes.TrimSpace(stdout.Bytes())
+		if bytes.HasPrefix(output, []byte("{")) {
+			var credential execCredential
+			if err := json.Unmarshal(output, &credential); err != nil {
+				return nil, fmt.Errorf("decoding the output of %s: %w", config.Command, err)
+			}
+			if credential.Status == nil {
+				return nil, fmt.Errorf("output of %s has no status", config.Command)
+			}
+			token := &Token{AccessToken: credential.Status.Token}
+			if credential.Status.ExpirationTimestamp != nil {
+				token.Expiry = *credential.Status.ExpirationTimestamp
+			}
+			return token, nil
+		}
+		return &Token{AccessToken: string(output)}, nil
+	})
+}
+
+// OIDCExchangeConfig describes an OAuth 2.0 token exchange (RFC 8693) with
+// an OIDC issuer trusted by the Argo CD server.
+type OIDCExchangeConfig struct {
+	// Issuer is used to discover the token endpoint when TokenURL is empty
+	Issuer       string
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Audience     string
+	Scopes       []string
+	// SubjectToken supplies the token exchanged, such as the identity token
+	// of a CI job
+	SubjectToken TokenSource
+	// SubjectTokenType defaults to urn:ietf:params:oauth:token-type:jwt
+	SubjectTokenType string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+type oidcExchangeSource struct {
+	config OIDCExchangeConfig
+	// tokenURL caches the discovered token endpoint
+	mu       sync.Mutex
+	tokenURL string
+}
+
+//OIDCExchangeTokenSource exchanges the subject token for a token issued to
+//the Argo CD client. The ID token of the response is used when there is one.
+func OIDCExchangeTokenSource(config OIDCExchangeConfig) TokenSource {
+	return &oidcExchangeSource{config: config, tokenURL: config.TokenURL}
+}
+
+func (s *oidcExchangeSource) httpClient() *http.Client {
+	if s.config.HTTPClient != nil {
+		return s.config.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+//endpoint returns the token endpoint, discovering it from the issuer once
+func (s *oidcExchangeSource) endpoint(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.tokenURL) > 0 {
+		return s.tokenURL, nil
+	}
+	if len(s.config.Issuer) == 0 {
+		return "", errors.New("neither an issuer nor a token URL is configured")
+	}
+	var discovery struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}
+	u := strings.TrimSuffix(s.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := s.doJSON(ctx, http.MethodGet, u, nil, &discovery); err != nil {
+		return "", fmt.Errorf("discovering the token endpoint: %w", err)
+	}
+	if len(discovery.TokenEndpoint) == 0 {
+		return "", errors.New("issuer advertises no token endpoint")
+	}
+	s.tokenURL = discovery.TokenEndpoint
+	return s.tokenURL, nil
+}
+
+func (s *oidcExchangeSource) Token(ctx context.Context) (*Token, error) {
+	if s.config.SubjectToken == nil {
+		return nil, errors.New("no subject token source is configured")
+	}
+	subject, err := s.config.SubjectToken.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("subject token: %w", err)
+	}
+	tokenURL, err := s.endpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subjectType := s.config.SubjectTokenType
+	if len(subjectType) == 0 {
+		subjectType = "urn:ietf:params:oauth:token-type:jwt"
+	}
+	form := url.Values{
+		"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+		"subject_token":      {subject.AccessToken},
+		"subject_token_type": {subjectType},
+	}
+	if len(s.config.ClientID) > 0 {
+		form.Set("client_id", s.config.ClientID)
+	}
+	if len(s.config.ClientSecret) > 0 {
+		form.Set("client_secret", s.config.ClientSecret)
+	}
+	if len(s.config.Audience) > 0 {
+		form.Set("audience", s.config.Audience)
+	}
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+	var response struct {
+		AccessToken string      `json:"access_token"`
+		IDToken     string      `json:"id_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+	if err = s.doJSON(ctx, http.MethodPost, tokenURL, form, &response); err != nil {
+		return nil, fmt.Errorf("exchanging the token: %w", err)
+	}
+	token := &Token{AccessToken: response.AccessToken}
+	if len(response.IDToken) > 0 {
+		token.AccessToken = response.IDToken
+	}
+	if seconds, err := strconv.ParseInt(response.ExpiresIn.String(), 10, 64); err == nil && seconds > 0 {
+		token.Expiry = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
+}
+
+//doJSON sends form, if any, to u and decodes the JSON response into v
+func (s *oidcExchangeSource) doJSON(ctx context.Context, method, u string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(data, &oauthErr) == nil && len(oauthErr.Error) > 0 {
+			return fmt.Errorf("%d %s: %s", resp.StatusCode, oauthErr.Error, oauthErr.Description)
+		}
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.Unmarshal(data, v)
+}
+
+//ProjectRoleTokenSource creates tokens for a project role through client,
+//which must be allowed to, each valid for expiresIn or without expiry when
+//expiresIn is zero
+func ProjectRoleTokenSource(client *Client, projectName, role string, expiresIn time.Duration) TokenSource {
+	return TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		result, _, err := client.Projects.CreateTokenWithContext(ctx, project.ProjectTokenCreateRequest{
+			Project:   projectName,
+			Role:      role,
+			ExpiresIn: int64(expiresIn / time.Second),
+		})
+		if err != nil {
+			return nil, err
+		}
+		token := &Token{AccessToken: result.Token}
+		if expiresIn > 0 {
+			token.Expiry = time.Now().Add(expiresIn)
+		}
+		return token, nil
+	})
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTokenTestServer accepts the tokens currently in valid, and serves
+// project role tokens to the admin token.
+func newTokenTestServer(t *testing.T, valid *sync.Map) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		if _, ok := valid.Load(token); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid session","code":16,"message":"invalid session"}`))
+			return
+		}
+		if r.URL.Path == "/api/v1/projects/default/roles/ci/token" && token == "Bearer admin-token" {
+			var request struct {
+				ExpiresIn int64 `json:"expiresIn"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.ExpiresIn != 3600 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token":"role-token"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"items":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFileTokenSource(t *testing.T) {
+	var valid sync.Map
+	server := newTokenTestServer(t, &valid)
+	path := filepath.Join(t.TempDir(), "token")
+	rotate := func(token string) {
+		if err := ioutil.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		valid = sync.Map{}
+		valid.Store("Bearer "+token, true)
+	}
+	rotate("first")
+	client, err := NewClient(server.URL, "", "", "", WithTokenSource(FileTokenSource(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	// the sidecar rotates the token, the client rereads it once rejected
+	rotate("second")
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if client.getToken() != "second" {
+		t.Fatalf("expected the rotated token, got %q", client.getToken())
+	}
+}
+
+func TestExecTokenSource(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	var valid sync.Map
+	valid.Store("Bearer exec-token", true)
+	server := newTokenTestServer(t, &valid)
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	credential := fmt.Sprintf(`{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"exec-token","expirationTimestamp":%q}}`,
+		expiry.Format(time.RFC3339))
+	client, err := NewClient(server.URL, "", "", "", WithTokenSource(ExecTokenSource(ExecConfig{
+		Command: "sh",
+		Args:    []string{"-c", `echo "$CREDENTIAL"`},
+		Env:     []string{"CREDENTIAL=" + credential},
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, exp := client.getTokenExpiry(); !exp.Equal(expiry) {
+		t.Fatalf("expected the plugin expiry %v, got %v", expiry, exp)
+	}
+
+	bare := ExecTokenSource(ExecConfig{Command: "sh", Args: []string{"-c", "echo bare-token"}})
+	if token, err := bare.Token(context.Background()); err != nil || token.AccessToken != "bare-token" {
+		t.Fatalf("unexpected bare token %v, %v", token, err)
+	}
+	failing := ExecTokenSource(ExecConfig{Command: "sh", Args: []string{"-c", "echo denied >&2; exit 1"}})
+	if _, err := failing.Token(context.Background()); err == nil {
+		t.Fatal("expected the plugin failure to be reported")
+	}
+}
+
+func TestOIDCExchangeTokenSource(t *testing.T) {
+	var issuer *httptest.Server
+	issuer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_, _ = fmt.Fprintf(w, `{"issuer":%q,"token_endpoint":%q}`, issuer.URL, issuer.URL+"/token")
+		case "/token":
+			if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:token-exchange" ||
+				r.FormValue("subject_token") != "ci-identity" || r.FormValue("client_id") != "argo-cd-cli" ||
+				r.FormValue("scope") != "openid groups" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":"invalid_request","error_description":"unexpected form"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"access","id_token":"id-token","expires_in":3600,"token_type":"Bearer"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer issuer.Close()
+	var valid sync.Map
+	valid.Store("Bearer id-token", true)
+	server := newTokenTestServer(t, &valid)
+	client, err := NewClient(server.URL, "", "", "", WithTokenSource(OIDCExchangeTokenSource(OIDCExchangeConfig{
+		Issuer:   issuer.URL,
+		ClientID: "argo-cd-cli",
+		Scopes:   []string{"openid", "groups"},
+		SubjectToken: TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+			return &Token{AccessToken: "ci-identity"}, nil
+		}),
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, exp := client.getTokenExpiry(); time.Until(exp) < 59*time.Minute {
+		t.Fatalf("expected the token to expire in an hour, got %v", exp)
+	}
+
+	rejected := OIDCExchangeTokenSource(OIDCExchangeConfig{
+		TokenURL: issuer.URL + "/token",
+		SubjectToken: TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+			return &Token{AccessToken: "someone-else"}, nil
+		}),
+	})
+	if _, err = rejected.Token(context.Background()); err == nil {
+		t.Fatal("expected the exchange to be rejected")
+	}
+}
+
+func TestProjectRoleTokenSource(t *testing.T) {
+	var valid sync.Map
+	valid.Store("Bearer admin-token", true)
+	valid.Store("Bearer role-token", true)
+	server := newTokenTestServer(t, &valid)
+	admin, err := NewClient(server.URL, "", "", "admin-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(server.URL, "", "", "", WithTokenSource(ProjectRoleTokenSource(admin, "default", "ci", time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); err != nil {
+		t.Fatal(err)
+	}
+	if client.getToken() != "role-token" {
+		t.Fatalf("expected the role token, got %q", client.getToken())
+	}
+}
+
+func TestTokenSourceError(t *testing.T) {
+	var valid sync.Map
+	server := newTokenTestServer(t, &valid)
+	failure := errors.New("vault is sealed")
+	client, err := NewClient(server.URL, "", "", "", WithTokenSource(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		return nil, failure
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.List(""); !errors.Is(err, failure) {
+		t.Fatalf("expected the token source error, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies this client as the instrumentation library of spans.
+const tracerName = "github.com/kube-all/go-argocd"
+
+//WithTracing records an OpenTelemetry span for every service method call,
+//named after the method like ApplicationService.Sync, and propagates the
+//trace context to the server. A nil provider or propagator falls back to the
+//global one registered with otel.
+func WithTracing(provider trace.TracerProvider, propagator propagation.TextMapPropagator) ClientOptionFunc {
+	return func(c *Client) error {
+		c.instrumentation = append(c.instrumentation, tracingMiddleware(provider, propagator))
+		return nil
+	}
+}
+
+func tracingMiddleware(provider trace.TracerProvider, propagator propagation.TextMapPropagator) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call *Call) (*http.Response, error) {
+			p, tp := propagator, provider
+			if p == nil {
+				p = otel.GetTextMapPropagator()
+			}
+			if tp == nil {
+				tp = otel.GetTracerProvider()
+			}
+			ctx, span := tp.Tracer(tracerName).Start(ctx, call.Service+"."+call.Operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(call.Method),
+					attribute.String("argocd.path", call.Path),
+				))
+			defer span.End()
+			for k, v := range call.Attributes {
+				span.SetAttributes(attribute.String(k, v))
+			}
+			p.Inject(ctx, propagation.HeaderCarrier(call.Header))
+
+			resp, err := next(ctx, call)
+			if call.Attempts > 1 {
+				span.SetAttributes(attribute.Int("argocd.retry_count", call.Attempts-1))
+			}
+			if resp != nil {
+				span.SetAttributes(semconv.HTTPStatusCodeKey.Int(resp.StatusCode))
+			}
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return resp, err
+		}
+	}
+}
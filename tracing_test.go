@@ -0,0 +1,122 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracingSpan(t *testing.T) {
+	var calls int32
+	var traceparent atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent.Store(r.Header.Get("Traceparent"))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"metadata":{"name":"guestbook"}}`))
+	}))
+	defer server.Close()
+	var events []RetryEvent
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client, err := NewClient(server.URL, "", "", "token",
+		WithTracing(provider, propagation.TraceContext{}), WithRetryPolicy(testRetryPolicy(&events)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	name := "guestbook"
+	if _, _, err = client.Applications.GetWithContext(ctx, application.ApplicationQuery{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "ApplicationService.Get" || span.SpanKind() != trace.SpanKindClient {
+		t.Fatalf("unexpected span %s of kind %v", span.Name(), span.SpanKind())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("span should be a child of the caller span")
+	}
+	attrs := spanAttributes(span)
+	if attrs["argocd.application"].AsString() != "guestbook" || attrs["http.method"].AsString() != http.MethodGet ||
+		attrs["http.status_code"].AsInt64() != http.StatusOK || attrs["argocd.retry_count"].AsInt64() != 1 {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got, _ := traceparent.Load().(string); got != want {
+		t.Fatalf("expected traceparent %q, got %q", want, got)
+	}
+}
+
+func TestTracingError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":5,"message":"not found"}`))
+	}))
+	defer server.Close()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	client, err := NewClient(server.URL, "", "", "token", WithTracing(provider, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = client.Projects.Get("default"); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Status().Code != codes.Error || len(span.Events()) != 1 {
+		t.Fatalf("expected an error status and event, got %+v", span.Status())
+	}
+	attrs := spanAttributes(span)
+	if attrs["argocd.project"].AsString() != "default" || attrs["http.status_code"].AsInt64() != http.StatusNotFound {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+	if _, ok := attrs["argocd.retry_count"]; ok {
+		t.Fatal("retry count should only be set on retried calls")
+	}
+}
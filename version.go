@@ -17,8 +17,9 @@ limitations under the License.
 package v1
 
 import (
+	"context"
 	"github.com/argoproj/argo-cd/v2/pkg/apiclient/version"
-	"github.com/parnurzeal/gorequest"
+	"net/http"
 )
 
 type VersionService struct {
@@ -26,9 +27,14 @@ type VersionService struct {
 }
 
 //GetVersion returns version information of the API server
-func (s *VersionService) GetVersion() (version version.VersionMessage, resp gorequest.Response, errs []error) {
-	resp, _, errs = s.client.
-		newRequest(gorequest.GET, "api/version").
-		EndStruct(&version)
+func (s *VersionService) GetVersion() (version version.VersionMessage, resp *http.Response, err error) {
+	return s.GetVersionWithContext(context.Background())
+}
+
+//GetVersionWithContext is GetVersion with a context controlling cancellation and deadlines
+func (s *VersionService) GetVersionWithContext(ctx context.Context) (version version.VersionMessage, resp *http.Response, err error) {
+	resp, err = s.client.
+		newRequest(ctx, "VersionService.GetVersion", http.MethodGet, "api/version").
+		Do(&version)
 	return
 }
@@ -0,0 +1,254 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+	"github.com/argoproj/gitops-engine/pkg/health"
+	synccommon "github.com/argoproj/gitops-engine/pkg/sync/common"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// WaitCondition is a set of states ApplicationService.WaitFor waits for, to
+// be combined with |.
+type WaitCondition int
+
+const (
+	// WaitSynced waits for the application, or the resources selected, to
+	// be in sync
+	WaitSynced WaitCondition = 1 << iota
+	// WaitHealthy waits for the application, or the resources selected, to
+	// be healthy
+	WaitHealthy
+	// WaitOperation waits for the operation in progress to finish
+	WaitOperation
+	// WaitSuspended waits for the application, or the resources selected,
+	// to be suspended
+	WaitSuspended
+)
+
+// defaultWaitPollInterval is how often WaitFor gets the application when it
+// cannot watch it.
+const defaultWaitPollInterval = 5 * time.Second
+
+var (
+	// ErrApplicationDegraded is returned by WaitFor failing fast on a
+	// degraded application or resource
+	ErrApplicationDegraded = errors.New("argocd: application degraded")
+	// ErrOperationFailed is returned by WaitFor failing fast on a failed
+	// operation
+	ErrOperationFailed = errors.New("argocd: operation failed")
+	// ErrApplicationDeleted is returned by WaitFor when the application is
+	// deleted while waiting
+	ErrApplicationDeleted = errors.New("argocd: application deleted")
+)
+
+// WaitOptions configures ApplicationService.WaitFor.
+type WaitOptions struct {
+	// Conditions are the states to wait for, all of them, WaitSynced,
+	// WaitHealthy and WaitOperation when zero
+	Conditions WaitCondition
+	// Timeout bounds the wait, which otherwise lasts until ctx is done
+	Timeout time.Duration
+	// Resources restricts WaitSynced, WaitHealthy and WaitSuspended to the
+	// resources of the application matching one of them by group, kind,
+	// name and namespace when set
+	Resources []v1alpha1.SyncOperationResource
+	// FailFast ends the wait as soon as the application, or a resource
+	// selected, is degraded, or the operation waited for failed
+	FailFast bool
+	// Progress, when set, is called with every state of the application
+	// seen and the conditions it meets
+	Progress func(app *v1alpha1.Application, met WaitCondition)
+	// PollInterval is how often the application is fetched when it cannot
+	// be watched, 5s when zero
+	PollInterval time.Duration
+}
+
+//WaitFor blocks until the application name meets the conditions of options,
+//returning its last state seen. Changes are followed from the application
+//stream, falling back to polling when the server refuses to stream.
+func (s *ApplicationService) WaitFor(ctx context.Context, name string, options WaitOptions) (result v1alpha1.Application, err error) {
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+	if options.Conditions == 0 {
+		options.Conditions = WaitSynced | WaitHealthy | WaitOperation
+	}
+	result, _, err = s.GetWithContext(ctx, application.ApplicationQuery{Name: &name})
+	if err != nil {
+		return
+	}
+	return s.waitUntil(ctx, name, result, options.PollInterval, options.check)
+}
+
+//Refresh has the application name reconciled again, comparing it with its
+//sources, or regenerating its manifests first when hard, then waits for the
+//controller to complete the refresh and returns the application reconciled
+func (s *ApplicationService) Refresh(name string, hard bool) (result v1alpha1.Application, resp *http.Response, err error) {
+	return s.RefreshWithContext(context.Background(), name, hard)
+}
+
+//RefreshWithContext is Refresh with a context controlling cancellation and deadlines
+func (s *ApplicationService) RefreshWithContext(ctx context.Context, name string, hard bool) (result v1alpha1.Application, resp *http.Response, err error) {
+	previous, resp, err := s.GetWithContext(ctx, application.ApplicationQuery{Name: &name})
+	if err != nil {
+		return
+	}
+	refresh := string(v1alpha1.RefreshTypeNormal)
+	if hard {
+		refresh = string(v1alpha1.RefreshTypeHard)
+	}
+	result, resp, err = s.GetWithContext(ctx, application.ApplicationQuery{Name: &name, Refresh: &refresh})
+	if err != nil {
+		return
+	}
+	// the controller removes the annotation once the refresh is done
+	result, err = s.waitUntil(ctx, name, result, 0, func(app *v1alpha1.Application) (bool, error) {
+		_, pending := app.Annotations[v1alpha1.AnnotationKeyRefresh]
+		reconciledAt := app.Status.ReconciledAt
+		return !pending && reconciledAt != nil &&
+			(previous.Status.ReconciledAt == nil || !reconciledAt.Before(previous.Status.ReconciledAt)), nil
+	})
+	return
+}
+
+//waitUntil follows the application name, in state app, until done reports it
+//complete or fails, returning its last state seen. Changes are followed from the
+//application stream, falling back to polling every interval when the server
+//refuses to stream.
+func (s *ApplicationService) waitUntil(ctx context.Context, name string, app v1alpha1.Application, interval time.Duration, done func(app *v1alpha1.Application) (bool, error)) (result v1alpha1.Application, err error) {
+	result = app
+	if ok, err := done(&result); ok || err != nil {
+		return result, err
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	w := s.Watch(watchCtx, application.ApplicationQuery{Name: &name, ResourceVersion: &app.ResourceVersion})
+	// the watch is done by the time this returns
+	defer func() {
+		cancel()
+		for range w.Events() {
+		}
+	}()
+	for event := range w.Events() {
+		if event.Application.Name != app.Name {
+			continue
+		}
+		result = event.Application
+		if event.Type == watch.Deleted {
+			return result, fmt.Errorf("%w: %s", ErrApplicationDeleted, app.Name)
+		}
+		if ok, err := done(&result); ok || err != nil {
+			return result, err
+		}
+	}
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	if interval <= 0 {
+		interval = defaultWaitPollInterval
+	}
+	for {
+		if err = sleep(ctx, interval); err != nil {
+			return
+		}
+		current, _, err := s.GetWithContext(ctx, application.ApplicationQuery{Name: &name})
+		if IsNotFound(err) {
+			return result, fmt.Errorf("%w: %s", ErrApplicationDeleted, app.Name)
+		}
+		if err != nil {
+			return result, err
+		}
+		result = current
+		if ok, err := done(&result); ok || err != nil {
+			return result, err
+		}
+	}
+}
+
+//check reports whether app meets the conditions, or fails fast
+func (o *WaitOptions) check(app *v1alpha1.Application) (bool, error) {
+	var met WaitCondition
+	synced, healthy, suspended, degraded := true, true, true, false
+	if len(o.Resources) == 0 {
+		synced = app.Status.Sync.Status == v1alpha1.SyncStatusCodeSynced
+		healthy = app.Status.Health.Status == health.HealthStatusHealthy
+		suspended = app.Status.Health.Status == health.HealthStatusSuspended
+		degraded = app.Status.Health.Status == health.HealthStatusDegraded
+	}
+	for _, selected := range o.Resources {
+		resource := findResourceStatus(app.Status.Resources, selected)
+		if resource == nil {
+			synced, healthy, suspended = false, false, false
+			continue
+		}
+		synced = synced && resource.Status == v1alpha1.SyncStatusCodeSynced
+		status := health.HealthStatusHealthy
+		if resource.Health != nil {
+			status = resource.Health.Status
+		}
+		healthy = healthy && status == health.HealthStatusHealthy
+		suspended = suspended && status == health.HealthStatusSuspended
+		degraded = degraded || status == health.HealthStatusDegraded
+	}
+	state := app.Status.OperationState
+	if synced {
+		met |= WaitSynced
+	}
+	if healthy {
+		met |= WaitHealthy
+	}
+	if suspended {
+		met |= WaitSuspended
+	}
+	if app.Operation == nil && (state == nil || state.FinishedAt != nil) {
+		met |= WaitOperation
+	}
+	if o.Progress != nil {
+		o.Progress(app, met)
+	}
+	if o.FailFast && degraded {
+		return false, fmt.Errorf("%w: %s %s", ErrApplicationDegraded, app.Name, app.Status.Health.Message)
+	}
+	if o.FailFast && o.Conditions&WaitOperation != 0 && met&WaitOperation != 0 && state != nil &&
+		(state.Phase == synccommon.OperationFailed || state.Phase == synccommon.OperationError) {
+		return false, fmt.Errorf("%w: %s %s", ErrOperationFailed, app.Name, state.Message)
+	}
+	return met&o.Conditions == o.Conditions, nil
+}
+
+//findResourceStatus returns the status of the resource selected
+func findResourceStatus(resources []v1alpha1.ResourceStatus, selected v1alpha1.SyncOperationResource) *v1alpha1.ResourceStatus {
+	for i, r := range resources {
+		if r.Group == selected.Group && r.Kind == selected.Kind && r.Name == selected.Name &&
+			(len(selected.Namespace) == 0 || r.Namespace == selected.Namespace) {
+			return &resources[i]
+		}
+	}
+	return nil
+}
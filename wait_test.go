@@ -0,0 +1,203 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+// waitAppJSON is the guestbook application in the given state, with its
+// operation running unless finished.
+func waitAppJSON(resourceVersion, sync, health string, finished bool) string {
+	operation, finishedAt := `"operation":{"sync":{}},`, ""
+	if finished {
+		operation, finishedAt = "", `,"finishedAt":"2022-08-01T10:00:00Z"`
+	}
+	return fmt.Sprintf(`{"metadata":{"name":"guestbook","resourceVersion":%q},%s"status":{`+
+		`"sync":{"status":%q},"health":{"status":%q},`+
+		`"resources":[{"group":"apps","kind":"Deployment","namespace":"default","name":"web","status":"Synced","health":{"status":"Healthy"}},`+
+		`{"kind":"Service","namespace":"default","name":"web","status":%q}],`+
+		`"operationState":{"phase":"Running"%s}}}`,
+		resourceVersion, operation, sync, health, sync, finishedAt)
+}
+
+func TestWaitFor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/applications/guestbook":
+			_, _ = w.Write([]byte(waitAppJSON("1", "OutOfSync", "Missing", false)))
+		case "/api/v1/stream/applications":
+			if r.URL.Query().Get("name") != "guestbook" || r.URL.Query().Get("resourceVersion") != "1" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for _, app := range []string{
+				waitAppJSON("2", "Synced", "Progressing", false),
+				waitAppJSON("3", "Synced", "Healthy", true),
+			} {
+				_, _ = fmt.Fprintf(w, `{"result":{"type":"MODIFIED","application":%s}}`+"\n", app)
+			}
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress []string
+	app, err := client.Applications.WaitFor(context.Background(), "guestbook", WaitOptions{
+		FailFast: true,
+		Progress: func(app *v1alpha1.Application, met WaitCondition) {
+			progress = append(progress, fmt.Sprintf("%s:%d", app.ResourceVersion, met))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.ResourceVersion != "3" || fmt.Sprint(progress) != "[1:0 2:1 3:7]" {
+		t.Fatalf("unexpected wait for %s through %v", app.ResourceVersion, progress)
+	}
+
+	// the deployment alone is synced and healthy from the start
+	app, err = client.Applications.WaitFor(context.Background(), "guestbook", WaitOptions{
+		Conditions: WaitSynced | WaitHealthy,
+		Resources:  []v1alpha1.SyncOperationResource{{Group: "apps", Kind: "Deployment", Name: "web"}},
+	})
+	if err != nil || app.ResourceVersion != "1" {
+		t.Fatalf("unexpected wait for %s: %v", app.ResourceVersion, err)
+	}
+
+	_, err = client.Applications.WaitFor(context.Background(), "guestbook", WaitOptions{
+		Conditions: WaitSuspended,
+		Timeout:    100 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to time out, got %v", err)
+	}
+}
+
+func TestWaitForPolling(t *testing.T) {
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/applications/guestbook":
+			health := "Progressing"
+			if atomic.AddInt32(&gets, 1) == 3 {
+				health = "Degraded"
+			}
+			_, _ = w.Write([]byte(waitAppJSON(fmt.Sprint(gets), "Synced", health, true)))
+		case "/api/v1/stream/applications":
+			w.WriteHeader(http.StatusNotImplemented)
+			_, _ = w.Write([]byte(`{"error":"streaming not supported","code":12,"message":"streaming not supported"}`))
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := client.Applications.WaitFor(context.Background(), "guestbook", WaitOptions{
+		FailFast:     true,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if !errors.Is(err, ErrApplicationDegraded) || app.ResourceVersion != "3" {
+		t.Fatalf("expected the wait to fail degraded at 3, got %s: %v", app.ResourceVersion, err)
+	}
+}
+
+func TestApplicationRefresh(t *testing.T) {
+	app := func(resourceVersion, reconciledAt, annotations string) string {
+		return fmt.Sprintf(`{"metadata":{"name":"guestbook","resourceVersion":%q,"annotations":{%s}},"status":{"reconciledAt":%q}}`,
+			resourceVersion, annotations, reconciledAt)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/applications/guestbook":
+			if r.URL.Query().Get("refresh") == "" {
+				_, _ = w.Write([]byte(app("1", "2022-08-01T10:00:00Z", "")))
+				return
+			}
+			if r.URL.Query().Get("refresh") != "hard" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(app("2", "2022-08-01T10:00:00Z", `"argocd.argoproj.io/refresh":"hard"`)))
+		case "/api/v1/stream/applications":
+			if r.URL.Query().Get("resourceVersion") != "2" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"result":{"type":"MODIFIED","application":%s}}`+"\n", app("3", "2022-08-01T10:01:00Z", ""))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, _, err := client.Applications.Refresh("guestbook", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.ResourceVersion != "3" || refreshed.Status.ReconciledAt.Minute() != 1 {
+		t.Fatalf("unexpected application %+v", refreshed)
+	}
+}
+
+func TestWaitForAppNamespace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("appNamespace") != "team" {
+			t.Errorf("unexpected application namespace in %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/applications/guestbook":
+			_, _ = w.Write([]byte(waitAppJSON("1", "OutOfSync", "Missing", false)))
+		case "/api/v1/stream/applications":
+			if r.URL.Query().Get("name") != "guestbook" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"result":{"type":"MODIFIED","application":%s}}`+"\n", waitAppJSON("2", "Synced", "Healthy", true))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := client.Applications.WaitFor(context.Background(), "team/guestbook", WaitOptions{})
+	if err != nil || app.ResourceVersion != "2" {
+		t.Fatalf("unexpected wait for %s: %v", app.ResourceVersion, err)
+	}
+}
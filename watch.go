@@ -0,0 +1,226 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+// ApplicationWatch delivers the events of ApplicationService.Watch.
+type ApplicationWatch struct {
+	events chan v1alpha1.ApplicationWatchEvent
+	err    error
+}
+
+//Events returns the channel of application events, closed when the watch
+//ends
+func (w *ApplicationWatch) Events() <-chan v1alpha1.ApplicationWatchEvent {
+	return w.events
+}
+
+//Err returns why the watch ended, the context error when it was canceled. It
+//is only valid once Events is closed.
+func (w *ApplicationWatch) Err() error {
+	return w.err
+}
+
+//Watch streams the changes of the applications matching the name, projects,
+//selector and resourceVersion of query. A stream broken by the server or the
+//network is reopened with backoff from the last resourceVersion received,
+//until ctx is done or the server refuses the watch.
+func (s *ApplicationService) Watch(ctx context.Context, query application.ApplicationQuery) *ApplicationWatch {
+	w := &ApplicationWatch{events: make(chan v1alpha1.ApplicationWatchEvent)}
+	namespace := ""
+	if query.Name != nil {
+		var name string
+		namespace, name = splitApplicationName(*query.Name)
+		query.Name = &name
+	}
+	go func() {
+		defer close(w.events)
+		w.err = reconnect(ctx, func() (bool, error) {
+			return s.watch(ctx, &query, namespace, w.events)
+		})
+	}()
+	return w
+}
+
+//watch forwards the events of a single stream of the applications in
+//namespace, recording the resourceVersion to resume from in query
+func (s *ApplicationService) watch(ctx context.Context, query *application.ApplicationQuery, namespace string, events chan<- v1alpha1.ApplicationWatchEvent) (received bool, err error) {
+	name := ""
+	if query.Name != nil {
+		name = *query.Name
+	}
+	resp, err := s.client.
+		newRequest(ctx, "ApplicationService.Watch", http.MethodGet, apiV1Prefix+"stream/applications").
+		Attr(AttributeApplication, name).
+		Query(query).
+		Query(appNamespaceQuery(namespace)).
+		Stream()
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var event v1alpha1.ApplicationWatchEvent
+		if err = s.client.readStreamMessage(dec, &event); err != nil {
+			return
+		}
+		received = true
+		if rv := event.Application.ResourceVersion; len(rv) > 0 {
+			query.ResourceVersion = &rv
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+}
+
+// ResourceTreeUpdate is a snapshot of the resource tree of an application with
+// the node changes since the previous one. The first snapshot of a watch
+// reports every node as added. Nodes are identified by group, kind, namespace
+// and name, orphaned nodes included.
+type ResourceTreeUpdate struct {
+	Tree          v1alpha1.ApplicationTree
+	Added         []v1alpha1.ResourceNode
+	Removed       []v1alpha1.ResourceNode
+	HealthChanged []NodeHealthChange
+}
+
+// NodeHealthChange is a node whose health status changed.
+type NodeHealthChange struct {
+	Node v1alpha1.ResourceNode
+	// Previous is the former health of the node, nil when it had none
+	Previous *v1alpha1.HealthStatus
+}
+
+// ResourceTreeWatch delivers the updates of ApplicationService.WatchResourceTree.
+type ResourceTreeWatch struct {
+	updates chan ResourceTreeUpdate
+	err     error
+}
+
+//Updates returns the channel of resource tree updates, closed when the watch
+//ends
+func (w *ResourceTreeWatch) Updates() <-chan ResourceTreeUpdate {
+	return w.updates
+}
+
+//Err returns why the watch ended, the context error when it was canceled. It
+//is only valid once Updates is closed.
+func (w *ResourceTreeWatch) Err() error {
+	return w.err
+}
+
+//WatchResourceTree streams snapshots of the resource tree of the application
+//named in request, along with the nodes added, removed or whose health
+//changed. A broken stream is reopened with backoff, the diff of the first
+//snapshot received then being relative to the last one delivered.
+func (s *ApplicationService) WatchResourceTree(ctx context.Context, request application.ResourcesQuery) *ResourceTreeWatch {
+	w := &ResourceTreeWatch{updates: make(chan ResourceTreeUpdate)}
+	namespace, name := splitApplicationName(*request.ApplicationName)
+	request.ApplicationName = &name
+	go func() {
+		defer close(w.updates)
+		var previous *v1alpha1.ApplicationTree
+		w.err = reconnect(ctx, func() (bool, error) {
+			return s.watchResourceTree(ctx, request, namespace, &previous, w.updates)
+		})
+	}()
+	return w
+}
+
+//watchResourceTree forwards the snapshots of a single stream of the
+//application in namespace, diffed against *previous which it keeps up to date
+func (s *ApplicationService) watchResourceTree(ctx context.Context, request application.ResourcesQuery, namespace string, previous **v1alpha1.ApplicationTree, updates chan<- ResourceTreeUpdate) (received bool, err error) {
+	resp, err := s.client.
+		newRequest(ctx, "ApplicationService.WatchResourceTree", http.MethodGet, apiV1Prefix+"stream/applications/"+*request.ApplicationName+"/resource-tree").
+		Attr(AttributeApplication, *request.ApplicationName).
+		Query(&request).
+		Query(appNamespaceQuery(namespace)).
+		Stream()
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var tree v1alpha1.ApplicationTree
+		if err = s.client.readStreamMessage(dec, &tree); err != nil {
+			return
+		}
+		received = true
+		update := diffResourceTrees(*previous, tree)
+		*previous = &tree
+		select {
+		case updates <- update:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+}
+
+//diffResourceTrees compares tree to previous, nil for the first snapshot
+func diffResourceTrees(previous *v1alpha1.ApplicationTree, tree v1alpha1.ApplicationTree) ResourceTreeUpdate {
+	update := ResourceTreeUpdate{Tree: tree}
+	before := map[string]v1alpha1.ResourceNode{}
+	if previous != nil {
+		for _, n := range treeNodes(*previous) {
+			before[n.FullName()] = n
+		}
+	}
+	after := map[string]bool{}
+	for _, n := range treeNodes(tree) {
+		after[n.FullName()] = true
+		old, ok := before[n.FullName()]
+		switch {
+		case !ok:
+			update.Added = append(update.Added, n)
+		case healthStatus(old.Health) != healthStatus(n.Health):
+			update.HealthChanged = append(update.HealthChanged, NodeHealthChange{Node: n, Previous: old.Health})
+		}
+	}
+	if previous != nil {
+		for _, n := range treeNodes(*previous) {
+			if !after[n.FullName()] {
+				update.Removed = append(update.Removed, n)
+			}
+		}
+	}
+	return update
+}
+
+func treeNodes(tree v1alpha1.ApplicationTree) []v1alpha1.ResourceNode {
+	nodes := make([]v1alpha1.ResourceNode, 0, len(tree.Nodes)+len(tree.OrphanedNodes))
+	return append(append(nodes, tree.Nodes...), tree.OrphanedNodes...)
+}
+
+func healthStatus(health *v1alpha1.HealthStatus) string {
+	if health == nil {
+		return ""
+	}
+	return string(health.Status)
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The kubeall.com Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/argoproj/argo-cd/v2/pkg/apiclient/application"
+	"github.com/argoproj/argo-cd/v2/pkg/apis/application/v1alpha1"
+)
+
+// fastStreamReconnect makes broken streams reopen immediately during a test.
+func fastStreamReconnect(t *testing.T) {
+	saved := streamReconnect
+	streamReconnect.InitialBackoff, streamReconnect.MaxBackoff = time.Millisecond, time.Millisecond
+	t.Cleanup(func() { streamReconnect = saved })
+}
+
+func watchEventJSON(eventType, name, resourceVersion string) string {
+	return fmt.Sprintf(`{"result":{"type":%q,"application":{"metadata":{"name":%q,"resourceVersion":%q}}}}`+"\n",
+		eventType, name, resourceVersion)
+}
+
+func TestApplicationWatch(t *testing.T) {
+	fastStreamReconnect(t)
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/stream/applications" || r.URL.Query().Get("projects") != "default" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			if r.URL.Query().Get("resourceVersion") != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(watchEventJSON("ADDED", "guestbook", "1")))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(watchEventJSON("MODIFIED", "guestbook", "2")))
+		case 2:
+			// the stream resumes where it broke and fails with a transient error
+			if r.URL.Query().Get("resourceVersion") != "2" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(watchEventJSON("DELETED", "guestbook", "3")))
+			_, _ = w.Write([]byte(`{"error":{"grpc_code":14,"http_code":503,"message":"server shutting down","http_status":"Service Unavailable"}}` + "\n"))
+		case 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":"permission denied","code":7,"message":"permission denied"}`))
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch := client.Applications.Watch(context.Background(), application.ApplicationQuery{Projects: []string{"default"}})
+	var got []string
+	for event := range watch.Events() {
+		got = append(got, fmt.Sprintf("%s %s", event.Type, event.Application.ResourceVersion))
+	}
+	if fmt.Sprint(got) != "[ADDED 1 MODIFIED 2 DELETED 3]" {
+		t.Fatalf("unexpected events %v", got)
+	}
+	if !IsPermissionDenied(watch.Err()) || connections != 4 {
+		t.Fatalf("expected the watch to end refused after 4 connections, got %v after %d", watch.Err(), connections)
+	}
+}
+
+func TestApplicationWatchCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(watchEventJSON("ADDED", "guestbook", "1")))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	watch := client.Applications.Watch(ctx, application.ApplicationQuery{})
+	if event := <-watch.Events(); event.Application.Name != "guestbook" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	cancel()
+	for range watch.Events() {
+	}
+	if watch.Err() != context.Canceled {
+		t.Fatalf("expected the watch to end canceled, got %v", watch.Err())
+	}
+}
+
+func TestWatchResourceTree(t *testing.T) {
+	fastStreamReconnect(t)
+	node := func(kind, name, health string) string {
+		return fmt.Sprintf(`{"kind":%q,"namespace":"default","name":%q,"health":{"status":%q}}`, kind, name, health)
+	}
+	snapshot := func(nodes ...string) string {
+		return `{"result":{"nodes":[` + strings.Join(nodes, ",") + `]}}` + "\n"
+	}
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/stream/applications/guestbook/resource-tree" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			_, _ = w.Write([]byte(snapshot(node("Deployment", "web", "Progressing"), node("Service", "web", "Healthy"))))
+			_, _ = w.Write([]byte(snapshot(node("Deployment", "web", "Healthy"), node("Service", "web", "Healthy"),
+				node("Pod", "web-1", "Healthy"))))
+		case 2:
+			_, _ = w.Write([]byte(snapshot(node("Deployment", "web", "Healthy"), node("Pod", "web-1", "Healthy"))))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"not found","code":5,"message":"application guestbook not found"}`))
+		}
+	}))
+	defer server.Close()
+	client, err := NewClient(server.URL, "", "", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "guestbook"
+	watch := client.Applications.WatchResourceTree(context.Background(), application.ResourcesQuery{ApplicationName: &name})
+	names := func(nodes []v1alpha1.ResourceNode) string {
+		var s []string
+		for _, n := range nodes {
+			s = append(s, n.Kind+"/"+n.Name)
+		}
+		return strings.Join(s, ",")
+	}
+	var got []string
+	for update := range watch.Updates() {
+		var changed []string
+		for _, c := range update.HealthChanged {
+			changed = append(changed, fmt.Sprintf("%s/%s %s->%s", c.Node.Kind, c.Node.Name, c.Previous.Status, c.Node.Health.Status))
+		}
+		got = append(got, fmt.Sprintf("+[%s] -[%s] ~[%s] %d", names(update.Added), names(update.Removed),
+			strings.Join(changed, ","), len(update.Tree.Nodes)))
+	}
+	want := []string{
+		"+[Deployment/web,Service/web] -[] ~[] 2",
+		"+[Pod/web-1] -[] ~[Deployment/web Progressing->Healthy] 3",
+		"+[] -[Service/web] ~[] 2",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected updates\n%s", strings.Join(got, "\n"))
+	}
+	if !IsNotFound(watch.Err()) {
+		t.Fatalf("expected the watch to end with not found, got %v", watch.Err())
+	}
+}